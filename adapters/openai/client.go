@@ -3,6 +3,7 @@ package openai
 import (
 	"context"
 	"errors"
+	"net/http"
 
 	"github.com/josebalius/typechat-go"
 	"github.com/sashabaranov/go-openai"
@@ -36,6 +37,12 @@ func openaiRole(r typechat.Role) (string, error) {
 	return role, nil
 }
 
+// RepairRole sends the requests to fix a response as user messages, which the models follow more reliably than system
+// messages in the middle of a conversation.
+func (c *Client) RepairRole() typechat.Role {
+	return typechat.RoleUser
+}
+
 func (c *Client) Do(ctx context.Context, prompt []typechat.Message) (string, error) {
 	var messages []openai.ChatCompletionMessage
 	for _, m := range prompt {
@@ -57,7 +64,7 @@ func (c *Client) Do(ctx context.Context, prompt []typechat.Message) (string, err
 	}
 	resp, err := c.client.CreateChatCompletion(ctx, params)
 	if err != nil {
-		return "", err
+		return "", retryableError(err)
 	}
 
 	if len(resp.Choices) == 0 {
@@ -66,3 +73,22 @@ func (c *Client) Do(ctx context.Context, prompt []typechat.Message) (string, err
 
 	return resp.Choices[0].Message.Content, nil
 }
+
+// retryableError marks rate limits and server errors as retryable so the prompt retries them.
+func retryableError(err error) error {
+	var status int
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		status = reqErr.HTTPStatusCode
+	}
+
+	if status == http.StatusTooManyRequests || status >= http.StatusInternalServerError {
+		return typechat.RetryableError(err, 0)
+	}
+
+	return err
+}
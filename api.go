@@ -0,0 +1,256 @@
+package typechat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// apiMethod is a method of the API interface programs are written against.
+type apiMethod struct {
+	name        string
+	description string
+	// named reports whether parameter names were registered for the method.
+	named   bool
+	params  []apiParam
+	results []apiResult // without a trailing error
+	error   bool        // whether the method returns an error last
+}
+
+type apiParam struct {
+	name        string
+	description string
+	typ         reflect.Type
+	expr        *typeExpr
+}
+
+type apiResult struct {
+	typ  reflect.Type
+	expr *typeExpr // nil if the type cannot be described by a schema
+}
+
+// goName returns the type of the result as it is written in Go API definitions, with anonymous structs indented by
+// indent.
+func (r apiResult) goName(indent string) string {
+	if r.expr == nil {
+		return qualifiedName.ReplaceAllString(r.typ.String(), "")
+	}
+
+	return goExpr(r.expr, indent)
+}
+
+// apiSchema describes the methods of an API interface, declaring the types of their parameters and results in a
+// schema.
+type apiSchema struct {
+	name    string
+	decls   []*typeDecl // the declarations added to the schema by the API
+	methods []*apiMethod
+	byName  map[string]*apiMethod
+}
+
+// newAPISchema describes the API interface t, declaring the types of its methods in s. Programs share the schema of
+// their response, so API types named like Program or FunctionCall are declared under distinct names.
+func newAPISchema(t reflect.Type, s *schema) (*apiSchema, error) {
+	if t.Kind() != reflect.Interface {
+		return nil, errors.New("top-level type must be an interface")
+	}
+	for _, name := range registeredMethods(t) {
+		if _, ok := t.MethodByName(name); !ok {
+			return nil, fmt.Errorf("method %s is registered but %s has no such method", name, t.Name())
+		}
+	}
+
+	a := &apiSchema{
+		name:   t.Name(),
+		byName: make(map[string]*apiMethod),
+	}
+	first := len(s.decls)
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		doc := methodDescription(t, method.Name)
+		m := &apiMethod{
+			name:        method.Name,
+			description: doc.description,
+			named:       len(doc.params) > 0,
+		}
+
+		params, _ := apiParams(method.Type)
+		if len(doc.params) > len(params) {
+			s.rollback(first)
+			return nil, fmt.Errorf("method %s: %d parameters registered, it has %d", method.Name, len(doc.params),
+				len(params))
+		}
+		for j, param := range params {
+			e, err := s.expr(param, true)
+			if err != nil {
+				s.rollback(first)
+				return nil, fmt.Errorf("method %s: parameter %d: %w", method.Name, j, err)
+			}
+
+			p := apiParam{name: fmt.Sprintf("arg%d", j), typ: param, expr: e}
+			if j < len(doc.params) {
+				if doc.params[j].Name != "" {
+					p.name = doc.params[j].Name
+				}
+				p.description = doc.params[j].Description
+			}
+			m.params = append(m.params, p)
+		}
+
+		for j := 0; j < method.Type.NumOut(); j++ {
+			out := method.Type.Out(j)
+			if j == method.Type.NumOut()-1 && out == errorType {
+				m.error = true
+				continue
+			}
+			// results are never decoded from JSON, so types without a schema are only named
+			n := len(s.decls)
+			e, err := s.expr(out, true)
+			if err != nil {
+				s.rollback(n)
+			}
+			m.results = append(m.results, apiResult{typ: out, expr: e})
+		}
+
+		a.methods = append(a.methods, m)
+		a.byName[m.name] = m
+	}
+	a.decls = s.decls[first:]
+
+	return a, nil
+}
+
+// validate checks that every step of p calls a method of the API with arguments of the right types, and that
+// references point to earlier steps whose result has the type of the parameter, or of the part of it they are passed
+// as.
+func (a *apiSchema) validate(p Program) error {
+	var c validator
+	for i, step := range p.Steps {
+		path := fmt.Sprintf("$.Steps[%d]", i)
+
+		m, ok := a.byName[step.Name]
+		if !ok {
+			c.add(path+".Name", "%q is not a function of the API, expected one of %s", step.Name, a.methodNames())
+			continue
+		}
+		if len(step.Args) != len(m.params) {
+			c.add(path+".Args", "%s expects %d arguments, got %d", m.name, len(m.params), len(step.Args))
+			continue
+		}
+
+		c.ref = func(r Ref, want *typeExpr, path string) {
+			target := a.refTarget(&c, p, i, r, path)
+			if target != nil && !exprAssignable(want, target.results[0].expr) {
+				c.add(path, "reference to step %d, whose function %s returns %s, expected %s", r.Step, target.name,
+					target.results[0].goName(""), goExpr(want, ""))
+			}
+		}
+		for j, arg := range step.Args {
+			argPath := fmt.Sprintf("%s.Args[%d]", path, j)
+			if r, ok := arg.(Ref); ok {
+				target := a.refTarget(&c, p, i, r, argPath)
+				if target != nil && !target.results[0].typ.AssignableTo(m.params[j].typ) {
+					c.add(argPath, "reference to step %d, whose function %s returns %s, expected %s", r.Step,
+						target.name, target.results[0].goName(""), goExpr(m.params[j].expr, ""))
+				}
+				continue
+			}
+			c.expr(m.params[j].expr, jsonNumbers(arg), argPath)
+		}
+	}
+
+	if len(c.violations) > 0 {
+		return c.violations
+	}
+
+	return nil
+}
+
+// refTarget checks that a reference made by step i points to an earlier step whose function returns one value, and
+// returns that function. It returns nil if the reference is invalid or its step is reported on its own.
+func (a *apiSchema) refTarget(c *validator, p Program, i int, r Ref, path string) *apiMethod {
+	switch {
+	case r.Step < 0:
+		c.add(path, "reference to step %d, which does not exist", r.Step)
+		return nil
+	case r.Step >= i:
+		c.add(path, "reference to step %d, which does not come before step %d", r.Step, i)
+		return nil
+	}
+
+	m, ok := a.byName[p.Steps[r.Step].Name]
+	if !ok {
+		// reported with the step it refers to
+		return nil
+	}
+	if len(m.results) != 1 {
+		c.add(path, "reference to step %d, whose function %s returns %d values instead of 1", r.Step, m.name,
+			len(m.results))
+		return nil
+	}
+
+	return m
+}
+
+// exprAssignable reports whether a value of the type described by have can be used where want is expected, following
+// the assignability rules of Go for the types the expressions describe. have is nil for types without a schema.
+func exprAssignable(want, have *typeExpr) bool {
+	return want.kind == exprAny && !want.nullable || have != nil && exprEqual(want, have)
+}
+
+func exprEqual(a, b *typeExpr) bool {
+	if a.kind != b.kind || a.nullable != b.nullable {
+		return false
+	}
+
+	switch a.kind {
+	case exprScalar:
+		return a.scalar == b.scalar && a.format == b.format
+	case exprSlice:
+		return exprEqual(a.elem, b.elem)
+	case exprMap:
+		return exprEqual(a.key, b.key) && exprEqual(a.elem, b.elem)
+	case exprNamed:
+		// anonymous structs are declared again wherever they are used
+		return a.decl.typ == b.decl.typ
+	}
+
+	return true
+}
+
+func (a *apiSchema) methodNames() string {
+	names := make([]string, len(a.methods))
+	for i, m := range a.methods {
+		names[i] = m.name
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// jsonNumbers replaces the float64 numbers of a value decoded by json.Unmarshal with json.Number, as validateJSON
+// decodes them.
+func jsonNumbers(v any) any {
+	switch v := v.(type) {
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'f', -1, 64))
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = jsonNumbers(item)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			out[key] = jsonNumbers(value)
+		}
+		return out
+	}
+
+	return v
+}
@@ -0,0 +1,414 @@
+package typechat
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testOrderItem struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+}
+
+type testOrderRequest struct {
+	Items []testOrderItem `json:"items"`
+	Note  string          `json:"note,omitempty"`
+}
+
+type testOrder struct {
+	ID    int             `json:"id"`
+	Items []testOrderItem `json:"items"`
+}
+
+// testWeird cannot be described by a schema because of its channel.
+type testWeird struct {
+	Item    testOrderItem
+	Updates chan int
+}
+
+type testShopAPI interface {
+	CreateOrder(req testOrderRequest) (testOrder, error)
+	Ship(order testOrder, carrier string) error
+	Stock(sku string) int
+}
+
+type testShop struct {
+	shipped []testOrder
+}
+
+func (s *testShop) CreateOrder(req testOrderRequest) (testOrder, error) {
+	return testOrder{ID: 7, Items: req.Items}, nil
+}
+
+func (s *testShop) Ship(order testOrder, carrier string) error {
+	s.shipped = append(s.shipped, order)
+	return nil
+}
+
+func (s *testShop) Stock(sku string) int {
+	return 3
+}
+
+func TestAPISchema(t *testing.T) {
+	api, err := newAPISchema(reflect.TypeOf((*testSocialAPI)(nil)).Elem(), newSchema())
+	if err != nil {
+		t.Fatalf("expected err to be nil, got %s", err)
+	}
+
+	t.Run("it accepts valid programs", func(t *testing.T) {
+		program := Program{Steps: []FunctionCall{
+			{Name: "Post", Args: []any{"hello"}},
+			{Name: "Tag", Args: []any{Ref{Step: 0}, []any{"news"}}},
+			{Name: "Schedule", Args: []any{Ref{Step: 0}, 1.5}},
+			{Name: "Count"},
+		}}
+
+		if err := api.validate(program); err != nil {
+			t.Errorf("expected err to be nil, got %s", err)
+		}
+	})
+
+	t.Run("it reports every invalid step", func(t *testing.T) {
+		tests := []struct {
+			steps    []FunctionCall
+			expected string
+		}{
+			{
+				[]FunctionCall{{Name: "Delete", Args: []any{float64(0)}}},
+				`$.Steps[0].Name: "Delete" is not a function of the API, expected one of Count, Post, Schedule, Tag`,
+			},
+			{
+				[]FunctionCall{{Name: "Post", Args: []any{"a", "b"}}},
+				"$.Steps[0].Args: Post expects 1 arguments, got 2",
+			},
+			{
+				[]FunctionCall{{Name: "Tag", Args: []any{"first", []any{"news", float64(1)}}}},
+				"$.Steps[0].Args[0]: expected an integer, got a string\n$.Steps[0].Args[1][1]: expected a string, got a number",
+			},
+			{
+				[]FunctionCall{{Name: "Tag", Args: []any{1.5, []any{}}}},
+				"$.Steps[0].Args[0]: expected an integer, got 1.5",
+			},
+			{
+				[]FunctionCall{{Name: "Post", Args: []any{Ref{Step: 0}}}},
+				"$.Steps[0].Args[0]: reference to step 0, which does not come before step 0",
+			},
+			{
+				[]FunctionCall{{Name: "Count"}, {Name: "Tag", Args: []any{Ref{Step: -1}, []any{Ref{Step: -2}}}}},
+				"$.Steps[1].Args[0]: reference to step -1, which does not exist\n" +
+					"$.Steps[1].Args[1][0]: reference to step -2, which does not exist",
+			},
+			{
+				[]FunctionCall{{Name: "Count"}, {Name: "Post", Args: []any{Ref{Step: 0}}}},
+				"$.Steps[1].Args[0]: reference to step 0, whose function Count returns int, expected string",
+			},
+			{
+				[]FunctionCall{
+					{Name: "Tag", Args: []any{float64(0), []any{}}},
+					{Name: "Tag", Args: []any{float64(0), []any{Ref{Step: 0}}}},
+				},
+				"$.Steps[1].Args[1][0]: reference to step 0, whose function Tag returns 0 values instead of 1",
+			},
+		}
+
+		for _, test := range tests {
+			err := api.validate(Program{Steps: test.steps})
+			if err == nil || err.Error() != test.expected {
+				t.Errorf("expected err to be %q, got %v", test.expected, err)
+			}
+		}
+	})
+
+	t.Run("it rejects API types that cannot be described", func(t *testing.T) {
+		type API interface {
+			Send(ch chan string)
+		}
+
+		_, err := newAPISchema(reflect.TypeOf((*API)(nil)).Elem(), newSchema())
+		if err == nil || !strings.HasPrefix(err.Error(), "method Send: parameter 0: ") {
+			t.Errorf("expected an error for the channel parameter, got %v", err)
+		}
+
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			t.Fatal("expected the model not to be called")
+			return "", nil
+		})
+		_, err = NewPrompt[API](m, "").CreateProgram(context.Background())
+		expected := "failed to execute prompt: failed to build prompt: failed to get definition of schema: method Send"
+		if err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("expected err to start with %q, got %v", expected, err)
+		}
+	})
+
+	t.Run("it accepts steps leaving out the arguments of functions without parameters", func(t *testing.T) {
+		m := mockModelClient{response: `{"Steps": [{"Name": "Count"}]}`}
+
+		var report Report
+		program, err := NewPrompt[testSocialAPI](m, "", PromptReport[testSocialAPI](&report)).
+			CreateProgram(context.Background())
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if len(program.Steps) != 1 || program.Steps[0].Args != nil {
+			t.Errorf("expected one step without arguments, got %+v", program)
+		}
+		if len(report.Attempts) != 1 {
+			t.Errorf("expected no repairs, got %d attempts", len(report.Attempts))
+		}
+	})
+
+	t.Run("it sends invalid programs to the model for repair", func(t *testing.T) {
+		responses := []string{
+			`{"Steps": [{"Name": "Count", "Args": []}, {"Name": "Post", "Args": [{"@ref": 0}]}]}`,
+			`{"Steps": [{"Name": "Post", "Args": ["hello"]}, {"Name": "Tag", "Args": [{"@ref": 0}, ["news"]]}]}`,
+		}
+
+		var prompts [][]Message
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			prompts = append(prompts, prompt)
+			return responses[len(prompts)-1], nil
+		})
+
+		program, err := NewPrompt[testSocialAPI](m, "").CreateProgram(context.Background())
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if len(program.Steps) != 2 || program.Steps[1].Name != "Tag" {
+			t.Errorf("expected the repaired program, got %+v", program)
+		}
+
+		repair := prompts[1][len(prompts[1])-1].Content
+		expected := "$.Steps[1].Args[0]: reference to step 0, whose function Count returns int, expected string"
+		if !strings.Contains(repair, expected) {
+			t.Errorf("expected repair prompt to contain the violation, got %s", repair)
+		}
+	})
+
+	t.Run("it renders full type names and registered method documentation", func(t *testing.T) {
+		type API interface {
+			Post(ctx context.Context, message string, tags []string) (*testTicket, error)
+			Stats(window map[string]int) ([]float64, int)
+		}
+		RegisterMethod[API]("Post", "Post publishes a message.",
+			Param{Name: "message", Description: "the text of the post"},
+			Param{Name: "tags"},
+		)
+
+		a, err := newAPISchema(reflect.TypeOf((*API)(nil)).Elem(), newSchema())
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `
+type API interface {
+	// Post publishes a message.
+	// message: the text of the post
+	Post(message string, tags []string) (*testTicket, error)
+	Stats(map[string]int) ([]float64, int)
+}`
+		assertNameDefOuptut(t, goRenderer{}.api(a), expected)
+
+		expected = `
+interface API {
+    /**
+     * Post publishes a message.
+     * @param message the text of the post
+     */
+    Post(message: string, tags: string[]): testTicket | null;
+    Stats(arg0: Record<string, number>): [number[], number];
+}`
+		assertNameDefOuptut(t, tsRenderer{}.api(a), expected)
+	})
+
+	t.Run("it rejects registered methods the interface does not have", func(t *testing.T) {
+		type API interface {
+			Count() int
+		}
+		RegisterMethod[API]("Count", "Count returns the number of posts.")
+		RegisterMethod[API]("Cuont", "Cuont is misspelled.")
+
+		_, err := newAPISchema(reflect.TypeOf((*API)(nil)).Elem(), newSchema())
+		if err == nil || err.Error() != "method Cuont is registered but API has no such method" {
+			t.Errorf("expected an error for the misspelled method, got %v", err)
+		}
+	})
+
+	t.Run("it rejects more registered parameters than the method has", func(t *testing.T) {
+		type API interface {
+			Count(ctx context.Context) int
+		}
+		RegisterMethod[API]("Count", "", Param{Name: "ctx"})
+
+		_, err := newAPISchema(reflect.TypeOf((*API)(nil)).Elem(), newSchema())
+		if err == nil || err.Error() != "method Count: 1 parameters registered, it has 0" {
+			t.Errorf("expected a parameter count error, got %v", err)
+		}
+	})
+
+	t.Run("it declares the struct types of parameters and results", func(t *testing.T) {
+		msgs, err := newProgram[testShopAPI]("", goRenderer{}).prompt()
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `type testOrderItem struct {
+	SKU string ` + "`json:\"sku\"`" + `
+	Quantity int ` + "`json:\"quantity\"`" + `
+}
+type testOrderRequest struct {
+	Items []testOrderItem ` + "`json:\"items\"`" + `
+	Note string ` + "`json:\"note\"`" + ` // optional
+}
+type testOrder struct {
+	ID int ` + "`json:\"id\"`" + `
+	Items []testOrderItem ` + "`json:\"items\"`" + `
+}
+type testShopAPI interface {
+	CreateOrder(testOrderRequest) (testOrder, error)
+	Ship(testOrder, string) (error)
+	Stock(string) (int)
+}
+`
+		if !strings.HasSuffix(msgs[0].Content, expected) {
+			t.Errorf("expected the prompt to end with:\n%s\ngot:\n%s", expected, msgs[0].Content)
+		}
+	})
+
+	t.Run("it validates object arguments against their declaration", func(t *testing.T) {
+		a, err := newAPISchema(reflect.TypeOf((*testShopAPI)(nil)).Elem(), newSchema())
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		program := Program{Steps: []FunctionCall{
+			{Name: "CreateOrder", Args: []any{map[string]any{
+				"items": []any{map[string]any{"sku": "A1", "quantity": "two"}},
+			}}},
+			{Name: "Ship", Args: []any{Ref{Step: 0}, "post"}},
+		}}
+
+		expected := "$.Steps[0].Args[0].items[0].quantity: expected an integer, got a string"
+		if err := a.validate(program); err == nil || err.Error() != expected {
+			t.Errorf("expected err to be %q, got %v", expected, err)
+		}
+	})
+
+	t.Run("it checks the type of references nested in arguments", func(t *testing.T) {
+		a, err := newAPISchema(reflect.TypeOf((*testShopAPI)(nil)).Elem(), newSchema())
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		program := Program{Steps: []FunctionCall{
+			{Name: "Stock", Args: []any{"A1"}},
+			{Name: "CreateOrder", Args: []any{map[string]any{
+				"items": []any{
+					map[string]any{"sku": Ref{Step: 0}, "quantity": Ref{Step: 0}},
+					Ref{Step: 0},
+				},
+			}}},
+		}}
+
+		expected := "$.Steps[1].Args[0].items[0].sku: " +
+			"reference to step 0, whose function Stock returns int, expected string\n" +
+			"$.Steps[1].Args[0].items[1]: " +
+			"reference to step 0, whose function Stock returns int, expected testOrderItem"
+		if err := a.validate(program); err == nil || err.Error() != expected {
+			t.Errorf("expected err to be %q, got %v", expected, err)
+		}
+	})
+
+	t.Run("it names the types of references passed as arguments like nested ones", func(t *testing.T) {
+		a, err := newAPISchema(reflect.TypeOf((*testShopAPI)(nil)).Elem(), newSchema())
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		program := Program{Steps: []FunctionCall{
+			{Name: "Stock", Args: []any{"A1"}},
+			{Name: "CreateOrder", Args: []any{map[string]any{"items": []any{}}}},
+			{Name: "Ship", Args: []any{Ref{Step: 0}, Ref{Step: 1}}},
+		}}
+
+		expected := "$.Steps[2].Args[0]: reference to step 0, whose function Stock returns int, expected testOrder\n" +
+			"$.Steps[2].Args[1]: reference to step 1, whose function CreateOrder returns testOrder, expected string"
+		if err := a.validate(program); err == nil || err.Error() != expected {
+			t.Errorf("expected err to be %q, got %v", expected, err)
+		}
+	})
+
+	t.Run("it decodes object arguments into the parameter types", func(t *testing.T) {
+		impl := &testShop{}
+		program := Program{Steps: []FunctionCall{
+			{Name: "Stock", Args: []any{"A1"}},
+			{Name: "CreateOrder", Args: []any{map[string]any{
+				"items": []any{map[string]any{"sku": "A1", "quantity": Ref{Step: 0}}},
+			}}},
+			{Name: "Ship", Args: []any{Ref{Step: 1}, "post"}},
+		}}
+
+		if _, err := NewExecutor[testShopAPI](impl).Run(context.Background(), program); err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := []testOrder{{ID: 7, Items: []testOrderItem{{SKU: "A1", Quantity: 3}}}}
+		if !reflect.DeepEqual(impl.shipped, expected) {
+			t.Errorf("expected %+v to be shipped, got %+v", expected, impl.shipped)
+		}
+	})
+
+	t.Run("it declares API types named like program types under distinct names", func(t *testing.T) {
+		type Program struct {
+			Title string `json:"title"`
+		}
+		type FunctionCall struct {
+			Tool string `json:"tool"`
+		}
+		type API interface {
+			Publish(p Program) FunctionCall
+		}
+
+		msgs, err := newProgram[API]("", goRenderer{}).prompt()
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `type Program2 struct {
+	Title string ` + "`json:\"title\"`" + `
+}
+type FunctionCall2 struct {
+	Tool string ` + "`json:\"tool\"`" + `
+}
+type API interface {
+	Publish(Program2) (FunctionCall2)
+}
+`
+		if !strings.HasSuffix(msgs[0].Content, expected) {
+			t.Errorf("expected the prompt to end with:\n%s\ngot:\n%s", expected, msgs[0].Content)
+		}
+		if strings.Count(msgs[0].Content, "type Program struct") != 1 {
+			t.Errorf("expected Program to be declared once, got:\n%s", msgs[0].Content)
+		}
+	})
+
+	t.Run("it leaves out the declarations of result types without a schema", func(t *testing.T) {
+		type API interface {
+			Make() testWeird
+		}
+
+		msgs, err := newProgram[API]("", goRenderer{}).prompt()
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		content := msgs[0].Content
+		if strings.Contains(content, "testOrderItem") {
+			t.Errorf("expected no orphan declarations, got:\n%s", content)
+		}
+		if !strings.HasSuffix(content, "type API interface {\n\tMake() (testWeird)\n}\n") {
+			t.Errorf("expected the bare result type name, got:\n%s", content)
+		}
+	})
+}
@@ -1,34 +1,60 @@
 package typechat
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
-	"strings"
+	"text/template"
 )
 
 type promptBuilder interface {
 	prompt() ([]Message, error)
+	// responseSchema returns the schema responses are validated against and the declaration of the response type.
+	responseSchema() (*schema, *typeDecl, error)
+	// validate checks a decoded response against the rules of the prompt that its schema cannot express.
+	validate(output any) error
 }
 
 type builder[T any] struct {
 	input string
 	pt    promptType
 	pb    promptBuilder
+
+	repairOpts     repairOptions
+	repairTemplate *template.Template
+	rejected       []rejectedResponse
 }
 
-func newBuilder[T any](t promptType, input string) (*builder[T], error) {
+func newBuilder[T any](t promptType, input string, dialect SchemaDialect, repair repairOptions) (*builder[T], error) {
 	b := &builder[T]{
-		input: input,
-		pt:    t,
+		input:      input,
+		pt:         t,
+		repairOpts: repair,
+	}
+
+	r, err := newRenderer(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	switch repair.strategy {
+	case RepairLastOnly, RepairFullHistory, RepairCompact:
+	default:
+		return nil, fmt.Errorf("unknown repair strategy %s", repair.strategy)
+	}
+
+	b.repairTemplate, err = newRepairTemplate(repair)
+	if err != nil {
+		return nil, err
 	}
 
 	var pb promptBuilder
 	switch t {
 	case promptUserRequest:
-		pb = newUserRequest[T](input)
+		pb = newUserRequest[T](input, r)
 	case promptProgram:
-		pb = newProgram[T](input)
+		pb = newProgram[T](input, r)
 	default:
 		return nil, fmt.Errorf("unknown prompt type %s", t)
 	}
@@ -41,204 +67,77 @@ func (b *builder[T]) prompt() ([]Message, error) {
 	return b.pb.prompt()
 }
 
-func (b *builder[T]) repair(resp string, err error) ([]Message, error) {
-	msgs, err := b.pb.prompt()
+// parse validates the response against the schema of the prompt and decodes it into output.
+func (b *builder[T]) parse(resp string, output any) error {
+	_, root, err := b.pb.responseSchema()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	msgs = append(msgs, newAssistantMessage(resp))
-
-	var sb strings.Builder
-	if b.pt == promptUserRequest {
-		sb.WriteString(newline("The JSON object is invalid for the following reason:"))
-		sb.WriteString(newline(err.Error()))
-		sb.WriteString(newline("The following is a revised JSON object:"))
-	} else {
-		sb.WriteString(newline("The JSON program object is invalid for the following reason:"))
-		sb.WriteString(newline(err.Error()))
-		sb.WriteString(newline("The following is a revised JSON program object:"))
+	if err := validateJSON([]byte(resp), root); err != nil {
+		return err
 	}
 
-	msgs = append(msgs, newSystemMessage(sb.String()))
+	// Start from the zero value so nothing is left over from a previous response.
+	v := reflect.ValueOf(output).Elem()
+	v.Set(reflect.Zero(v.Type()))
 
-	return msgs, nil
+	return json.Unmarshal([]byte(resp), output)
 }
 
-func newline(s string) string {
-	return fmt.Sprintf("%s\n", s)
+// validate checks the decoded output further than its schema, once parse has succeeded.
+func (b *builder[T]) validate(output any) error {
+	return b.pb.validate(output)
 }
 
-func interfaceDef(t reflect.Type) (string, error) {
-	if t.Kind() != reflect.Interface {
-		return "", errors.New("top-level type must be an interface")
+// repair returns the prompt asking the model to fix resp, which was rejected for reason.
+func (b *builder[T]) repair(resp string, reason error) ([]Message, error) {
+	msgs, err := b.pb.prompt()
+	if err != nil {
+		return nil, err
 	}
 
-	var methods strings.Builder
-	for i := 0; i < t.NumMethod(); i++ {
-		method := t.Method(i)
-		name := method.Name
-		methodParts := []string{name}
+	b.rejected = append(b.rejected, rejectedResponse{response: resp, reason: reason})
 
-		var args []string
-		for j := 0; j < method.Type.NumIn(); j++ {
-			in := method.Type.In(j)
-			args = append(args, in.Name())
-		}
-		methodParts = append(methodParts, fmt.Sprintf("(%s)", strings.Join(args, ", ")))
-
-		var returns []string
-		for j := 0; j < method.Type.NumOut(); j++ {
-			out := method.Type.Out(j)
-			returns = append(returns, out.Name())
-		}
+	return b.repairMessages(msgs, b.rejected)
+}
 
-		if len(returns) > 0 {
-			methodParts = append(methodParts, fmt.Sprintf(" (%s)", strings.Join(returns, ", ")))
-		}
+func newline(s string) string {
+	return fmt.Sprintf("%s\n", s)
+}
 
-		methods.WriteString(fmt.Sprintf("\t%s\n", strings.Join(methodParts, "")))
+// interfaceDef renders the API interface t as Go.
+func interfaceDef(t reflect.Type) (string, error) {
+	a, err := newAPISchema(t, newSchema())
+	if err != nil {
+		return "", err
 	}
-	decl := fmt.Sprintf("type %s interface {\n%s}\n", t.Name(), methods.String())
 
-	return decl, nil
+	return goRenderer{}.api(a), nil
 }
 
 func structDef(t reflect.Type) (string, string, error) {
-	if t.Kind() != reflect.Struct {
-		return "", "", errors.New("top-level type must be a struct")
-	}
-
-	name, decl, err := typeDecls(t)
+	s, decl, err := structSchema(t)
 	if err != nil {
 		return "", "", err
 	}
 
-	return name, decl, nil
-}
-
-func typeDecls(t reflect.Type) (string, string, error) {
-	switch t.Kind() {
-	case reflect.Struct:
-		return typeStructDecl(t)
-	case reflect.Slice, reflect.Array:
-		return typeSliceArrayDecl(t)
-	case reflect.Map:
-		return typeMapDecl(t)
-	default:
-		return "", "", fmt.Errorf("unsupported type %s", t.Kind())
-	}
-}
-
-func typeStructDecl(t reflect.Type) (string, string, error) {
-	name := t.Name()
-
-	var decls strings.Builder
-	var fields strings.Builder
-
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		kind := field.Type.Kind()
-
-		if disallowedField(field.Type, false) {
-			return "", "", fmt.Errorf("field %s has disallowed type %s", field.Name, kind)
-		}
-
-		var structTags []string
-		if jsonTag := field.Tag.Get("json"); jsonTag != "" {
-			structTags = append(structTags, fmt.Sprintf("json:\"%s\"", jsonTag))
-		}
-
-		if descriptionTag := field.Tag.Get("description"); descriptionTag != "" {
-			structTags = append(structTags, fmt.Sprintf("description:\"%s\"", descriptionTag))
-		}
-
-		var structTag string
-		if len(structTags) > 0 {
-			structTag = fmt.Sprintf(" `%s`", strings.Join(structTags, " "))
-		}
-
-		name := field.Name
-		typName := kind.String()
-		if compositeField(kind) {
-			n, decl, err := typeDecls(field.Type)
-			if err != nil {
-				return "", "", fmt.Errorf("field %s: %w", name, err)
-			}
-			typName = n
-			decls.WriteString(decl)
-		}
-
-		fields.WriteString(fmt.Sprintf("\t%s %s%s\n", name, typName, structTag))
-	}
-	decls.WriteString(fmt.Sprintf("type %s struct {\n%s}\n", name, fields.String()))
-
-	return name, decls.String(), nil
+	return decl.name, goRenderer{}.decls(s), nil
 }
 
-func typeSliceArrayDecl(t reflect.Type) (string, string, error) {
-	var decls strings.Builder
-
-	elem := t.Elem()
-	name := elem.Name()
-	kind := elem.Kind()
-
-	if disallowedField(elem, true) {
-		return "", "", fmt.Errorf("slice element has disallowed type %s", kind)
-	}
-
-	if compositeField(kind) {
-		n, decl, err := typeDecls(elem)
-		if err != nil {
-			return "", "", err
-		}
-		name = n
-		decls.WriteString(decl)
-	}
-
-	if kind == reflect.Interface {
-		// empty interface case aka []any or []interface{}
-		name = "interface{}"
-	}
-	name = fmt.Sprintf("[]%s", name)
-
-	return name, decls.String(), nil
-}
-
-func typeMapDecl(t reflect.Type) (string, string, error) {
-	var decls strings.Builder
-
-	key := t.Key()
-	keyName := key.Name()
-	keyKind := key.Kind()
-
-	value := t.Elem()
-	valueName := value.Name()
-	valueKind := value.Kind()
-
-	if disallowedField(key, false) {
-		return "", "", fmt.Errorf("map key %s has disallowed type %s", keyName, keyKind)
-	}
-
-	if compositeField(keyKind) {
-		return "", "", fmt.Errorf("map key %s has composite type %s", keyName, keyKind)
-	}
-
-	if disallowedField(value, false) {
-		return "", "", fmt.Errorf("map value %s has disallowed type %s", valueName, valueKind)
+// structSchema returns the schema of the top-level struct t and its declaration.
+func structSchema(t reflect.Type) (*schema, *typeDecl, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, nil, errors.New("top-level type must be a struct")
 	}
 
-	if compositeField(valueKind) {
-		n, decl, err := typeDecls(value)
-		if err != nil {
-			return "", "", fmt.Errorf("failed to define type: %w", err)
-		}
-		valueName = n
-		decls.WriteString(decl)
+	s := newSchema()
+	decl, err := s.declare(t)
+	if err != nil {
+		return nil, nil, err
 	}
-	name := fmt.Sprintf("map[%s]%s", keyName, valueName)
 
-	return name, decls.String(), nil
+	return s, decl, nil
 }
 
 func compositeField(k reflect.Kind) bool {
@@ -248,13 +147,29 @@ func compositeField(k reflect.Kind) bool {
 		k == reflect.Struct
 }
 
+// namedScalar reports whether t is a named type, such as type UserID string, defined over a predeclared scalar type.
+func namedScalar(t reflect.Type) bool {
+	k := t.Kind()
+	return t.PkgPath() != "" && !compositeField(k) && k != reflect.Interface && k != reflect.Pointer
+}
+
+// indirect dereferences pointer types until it reaches a non-pointer type. It reports whether t was a pointer.
+func indirect(t reflect.Type) (reflect.Type, bool) {
+	var ptr bool
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		ptr = true
+	}
+
+	return t, ptr
+}
+
 func disallowedField(t reflect.Type, allowEmptyInterface bool) bool {
 	k := t.Kind()
 	if k == reflect.Complex64 ||
 		k == reflect.Complex128 ||
 		k == reflect.Chan ||
 		k == reflect.Func ||
-		k == reflect.Pointer ||
 		k == reflect.UnsafePointer {
 		return true
 	}
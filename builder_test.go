@@ -14,6 +14,19 @@ func assertNameDefOuptut(t *testing.T, def, expected string) {
 	}
 }
 
+type testUserID string
+
+func (testUserID) TypeDescription() string {
+	return "testUserID is the unique identifier of a user account."
+}
+
+type testCelsius float64
+
+type testProfile struct {
+	ID          testUserID
+	Temperature testCelsius
+}
+
 func TestNameDef(t *testing.T) {
 	t.Run("it supports simple struct of primitive types", func(t *testing.T) {
 		type Foo struct {
@@ -58,6 +71,32 @@ type Foo struct {
 		assertNameDefOuptut(t, def, expected)
 	})
 
+	t.Run("it interprets json tag options", func(t *testing.T) {
+		type Foo struct {
+			Name    string `json:"name,omitempty"`
+			Hidden  string `json:"-"`
+			Dash    string `json:"-,"`
+			Count   int64  `json:"count,string"`
+			Limit   *int   `json:",string"`
+			Comment string `json:",omitempty" description:"free text"`
+		}
+
+		_, def, err := structDef(reflect.TypeOf(Foo{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `
+type Foo struct {
+	Name string ` + "`" + `json:"name"` + "`" + ` // optional
+	Dash string ` + "`" + `json:"-,"` + "`" + `
+	Count string ` + "`" + `json:"count"` + "`" + ` // int64 encoded as a JSON string
+	Limit *string // optional, int encoded as a JSON string
+	Comment string ` + "`" + `description:"free text"` + "`" + ` // optional
+}`
+		assertNameDefOuptut(t, def, expected)
+	})
+
 	t.Run("it supports structs", func(t *testing.T) {
 		type Foo struct {
 			A int
@@ -187,4 +226,138 @@ type Foo struct {
 		assertNameDefOuptut(t, def, expected)
 	})
 
+	t.Run("it supports pointers as optional fields", func(t *testing.T) {
+		type Address struct {
+			City string
+		}
+
+		type Foo struct {
+			A *int
+			B *Address
+			C []*int
+			D map[string]*string
+		}
+
+		_, def, err := structDef(reflect.TypeOf(Foo{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `
+type Address struct {
+	City string
+}
+type Foo struct {
+	A *int // optional
+	B *Address // optional
+	C []*int
+	D map[string]*string
+}`
+
+		assertNameDefOuptut(t, def, expected)
+	})
+
+	t.Run("it flattens embedded structs", func(t *testing.T) {
+		type BaseEvent struct {
+			ID   string `json:"id"`
+			Kind string
+		}
+
+		type Meta struct {
+			Source string
+		}
+
+		type Foo struct {
+			BaseEvent
+			*Meta
+			Kind    int
+			private string
+		}
+
+		_, def, err := structDef(reflect.TypeOf(Foo{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `
+type Foo struct {
+	ID string ` + "`" + `json:"id"` + "`" + `
+	Source string
+	Kind int
+}`
+		assertNameDefOuptut(t, def, expected)
+	})
+
+	t.Run("it keeps tagged embedded structs as fields", func(t *testing.T) {
+		type Base struct {
+			ID string
+		}
+
+		type Foo struct {
+			Base `json:"base"`
+		}
+
+		_, def, err := structDef(reflect.TypeOf(Foo{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `
+type Base struct {
+	ID string
+}
+type Foo struct {
+	Base Base ` + "`" + `json:"base"` + "`" + `
+}`
+		assertNameDefOuptut(t, def, expected)
+	})
+
+	t.Run("it declares named primitive types", func(t *testing.T) {
+		RegisterDescription[testProfile]("testProfile holds the user settings.\nEvery user has exactly one.")
+
+		_, def, err := structDef(reflect.TypeOf(testProfile{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `
+// testUserID is the unique identifier of a user account.
+type testUserID string
+type testCelsius float64
+// testProfile holds the user settings.
+// Every user has exactly one.
+type testProfile struct {
+	ID testUserID
+	Temperature testCelsius
+}`
+		assertNameDefOuptut(t, def, expected)
+
+		type Foo struct {
+			Friends map[testUserID]testCelsius
+		}
+
+		_, def, err = structDef(reflect.TypeOf(Foo{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected = `
+// testUserID is the unique identifier of a user account.
+type testUserID string
+type testCelsius float64
+type Foo struct {
+	Friends map[testUserID]testCelsius
+}`
+		assertNameDefOuptut(t, def, expected)
+	})
+
+	t.Run("it rejects pointer map keys", func(t *testing.T) {
+		type Foo struct {
+			A map[*string]string
+		}
+
+		if _, _, err := structDef(reflect.TypeOf(Foo{})); err == nil {
+			t.Fatal("expected err to be non-nil")
+		}
+	})
 }
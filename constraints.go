@@ -0,0 +1,343 @@
+package typechat
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// constraints are the restrictions declared by the tc struct tag of a field, such as
+//
+//	Confidence float64 `tc:"min=0,max=1"`
+//	Currency   string  `tc:"pattern=^[A-Z]{3}$"`
+//	Priority   string  `tc:"oneof=low medium high"`
+//	Summary    string  `tc:"maxlen=280"`
+//
+// min and max bound numbers, len, minlen and maxlen bound the length of strings, slices and maps, pattern is a
+// regular expression strings must match and oneof lists the allowed values separated by spaces. The constraints are
+// shown to the model and checked on its responses.
+type constraints struct {
+	min, max               *float64
+	minText, maxText       string // min and max as written in the tag
+	length, minLen, maxLen *int
+	pattern                *regexp.Regexp
+	oneOf                  []string
+}
+
+// constraintShape is the kind of JSON value a field holds, which decides the constraints it accepts.
+type constraintShape int
+
+const (
+	shapeOther constraintShape = iota
+	shapeString
+	shapeNumber
+	shapeArray
+	shapeObject
+)
+
+func parseConstraints(tag string, e *typeExpr) (*constraints, error) {
+	if tag == "" {
+		return nil, nil
+	}
+
+	shape := exprShape(e)
+	c := &constraints{}
+
+	// Patterns may contain commas, so a part that does not start with a known key continues the previous value.
+	var keys []string
+	values := map[string]string{}
+	for _, part := range strings.Split(tag, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		switch key {
+		case "min", "max", "len", "minlen", "maxlen", "pattern", "oneof":
+			if !ok {
+				return nil, fmt.Errorf("constraint %s has no value", key)
+			}
+			if _, dup := values[key]; dup {
+				return nil, fmt.Errorf("constraint %s is declared twice", key)
+			}
+			keys = append(keys, key)
+			values[key] = value
+		default:
+			if len(keys) == 0 || keys[len(keys)-1] != "pattern" {
+				return nil, fmt.Errorf("unknown constraint %q", part)
+			}
+			values["pattern"] += "," + part
+		}
+	}
+
+	for _, key := range keys {
+		value := values[key]
+		switch key {
+		case "min", "max":
+			if shape != shapeNumber {
+				return nil, fmt.Errorf("constraint %s only applies to numbers", key)
+			}
+			n, err := parseNumber(value)
+			if err != nil {
+				return nil, fmt.Errorf("constraint %s: %w", key, err)
+			}
+			if key == "min" {
+				c.min, c.minText = &n, value
+			} else {
+				c.max, c.maxText = &n, value
+			}
+		case "len", "minlen", "maxlen":
+			if shape != shapeString && shape != shapeArray && shape != shapeObject {
+				return nil, fmt.Errorf("constraint %s only applies to strings, slices and maps", key)
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("constraint %s: %q is not a length", key, value)
+			}
+			switch key {
+			case "len":
+				c.length = &n
+			case "minlen":
+				c.minLen = &n
+			default:
+				c.maxLen = &n
+			}
+		case "pattern":
+			if shape != shapeString {
+				return nil, fmt.Errorf("constraint pattern only applies to strings")
+			}
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("constraint pattern: %w", err)
+			}
+			c.pattern = re
+		case "oneof":
+			if shape != shapeString && shape != shapeNumber {
+				return nil, fmt.Errorf("constraint oneof only applies to strings and numbers")
+			}
+			c.oneOf = strings.Fields(value)
+			if len(c.oneOf) == 0 {
+				return nil, fmt.Errorf("constraint oneof has no values")
+			}
+			if shape == shapeNumber {
+				for _, v := range c.oneOf {
+					if _, err := parseNumber(v); err != nil {
+						return nil, fmt.Errorf("constraint oneof: %w", err)
+					}
+				}
+			}
+		}
+	}
+
+	if c.min != nil && c.max != nil && *c.min > *c.max {
+		return nil, fmt.Errorf("constraint min %s is greater than max %s", c.minText, c.maxText)
+	}
+
+	return c, nil
+}
+
+// parseNumber parses a number of a tag. Numbers are written to schemas and compared to responses as they are
+// written, so they must be JSON numbers: .5, +1 and Inf are not.
+func parseNumber(s string) (float64, error) {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	if !json.Valid([]byte(s)) {
+		return 0, fmt.Errorf("%q is not a JSON number", s)
+	}
+
+	return n, nil
+}
+
+// exprShape returns the kind of JSON value e describes, following named types to their underlying type.
+func exprShape(e *typeExpr) constraintShape {
+	switch e.kind {
+	case exprScalar:
+		return scalarShape(e.scalar)
+	case exprSlice:
+		return shapeArray
+	case exprMap:
+		return shapeObject
+	case exprNamed:
+		switch e.decl.kind {
+		case declScalar:
+			return scalarShape(e.decl.scalar)
+		case declAlias:
+			return exprShape(e.decl.alias)
+		}
+	}
+
+	return shapeOther
+}
+
+// nullIsEmpty reports whether null decodes to an empty value of e, a slice or map that is not behind a pointer. Such a
+// value has to meet the length constraints of its field like any other.
+func nullIsEmpty(e *typeExpr) bool {
+	shape := exprShape(e)
+	return !e.nullable && (shape == shapeArray || shape == shapeObject)
+}
+
+func scalarShape(k reflect.Kind) constraintShape {
+	switch {
+	case k == reflect.String:
+		return shapeString
+	case intKind(k), uintKind(k), k == reflect.Float32, k == reflect.Float64:
+		return shapeNumber
+	}
+
+	return shapeOther
+}
+
+// oneOfLiterals returns the allowed values as JSON literals.
+func (c *constraints) oneOfLiterals(shape constraintShape) []string {
+	literals := make([]string, len(c.oneOf))
+	for i, v := range c.oneOf {
+		literals[i] = v
+		if shape == shapeString {
+			literals[i] = strconv.Quote(v)
+		}
+	}
+
+	return literals
+}
+
+// describe explains the constraints in words, for the comments of the Go and TypeScript schemas. The oneof values
+// are left out when withOneOf is false because the dialect already shows them in the type.
+func (c *constraints) describe(e *typeExpr, withOneOf bool) []string {
+	shape := exprShape(e)
+	unit := lengthUnit(shape)
+
+	var out []string
+	if c.min != nil {
+		out = append(out, fmt.Sprintf("minimum %s", c.minText))
+	}
+	if c.max != nil {
+		out = append(out, fmt.Sprintf("maximum %s", c.maxText))
+	}
+	if c.length != nil {
+		out = append(out, fmt.Sprintf("exactly %d %s", *c.length, unit))
+	}
+	if c.minLen != nil {
+		out = append(out, fmt.Sprintf("at least %d %s", *c.minLen, unit))
+	}
+	if c.maxLen != nil {
+		out = append(out, fmt.Sprintf("at most %d %s", *c.maxLen, unit))
+	}
+	if c.pattern != nil {
+		out = append(out, fmt.Sprintf("must match %s", c.pattern))
+	}
+	if c.oneOf != nil && withOneOf {
+		out = append(out, fmt.Sprintf("one of %s", strings.Join(c.oneOfLiterals(shape), ", ")))
+	}
+
+	return out
+}
+
+// jsonSchema adds the constraints to the JSON Schema of a property.
+func (c *constraints) jsonSchema(e *typeExpr, out map[string]any) {
+	shape := exprShape(e)
+	if c.min != nil {
+		out["minimum"] = json.Number(c.minText)
+	}
+	if c.max != nil {
+		out["maximum"] = json.Number(c.maxText)
+	}
+
+	minKey, maxKey := "minItems", "maxItems"
+	switch shape {
+	case shapeString:
+		minKey, maxKey = "minLength", "maxLength"
+	case shapeObject:
+		minKey, maxKey = "minProperties", "maxProperties"
+	}
+	if c.length != nil {
+		out[minKey] = *c.length
+		out[maxKey] = *c.length
+	}
+	if c.minLen != nil {
+		out[minKey] = *c.minLen
+	}
+	if c.maxLen != nil {
+		out[maxKey] = *c.maxLen
+	}
+
+	if c.pattern != nil {
+		out["pattern"] = c.pattern.String()
+	}
+	if c.oneOf != nil {
+		literals := c.oneOfLiterals(shape)
+		values := make([]any, len(literals))
+		for i, l := range literals {
+			values[i] = json.RawMessage(l)
+		}
+		// null is accepted for nullable fields, whose constraints only apply to other values
+		if e.nullable {
+			values = append(values, json.RawMessage("null"))
+		}
+		out["enum"] = values
+	}
+}
+
+func lengthUnit(shape constraintShape) string {
+	switch shape {
+	case shapeString:
+		return "characters"
+	case shapeObject:
+		return "entries"
+	}
+
+	return "items"
+}
+
+// constraints reports the constraints of a field violated by the decoded JSON value v, which has already been checked
+// to match the type of the field.
+func (c *validator) constraints(cs *constraints, e *typeExpr, v any, path string) {
+	shape := exprShape(e)
+
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		if err == nil {
+			if cs.min != nil && f < *cs.min {
+				c.add(path, "%s is less than the minimum %s", n, cs.minText)
+			}
+			if cs.max != nil && f > *cs.max {
+				c.add(path, "%s is greater than the maximum %s", n, cs.maxText)
+			}
+		}
+	}
+
+	var length int
+	switch v := v.(type) {
+	case string:
+		length = utf8.RuneCountInString(v)
+	case []any:
+		length = len(v)
+	case map[string]any:
+		length = len(v)
+	}
+	unit := lengthUnit(shape)
+	if cs.length != nil && length != *cs.length {
+		c.add(path, "has %d %s, expected exactly %d", length, unit, *cs.length)
+	}
+	if cs.minLen != nil && length < *cs.minLen {
+		c.add(path, "has %d %s, expected at least %d", length, unit, *cs.minLen)
+	}
+	if cs.maxLen != nil && length > *cs.maxLen {
+		c.add(path, "has %d %s, expected at most %d", length, unit, *cs.maxLen)
+	}
+
+	if s, ok := v.(string); ok && cs.pattern != nil && !cs.pattern.MatchString(s) {
+		c.add(path, "%s does not match %s", jsonText(v), cs.pattern)
+	}
+
+	if cs.oneOf != nil {
+		literals := cs.oneOfLiterals(shape)
+		for _, l := range literals {
+			if jsonEqual(l, v) {
+				return
+			}
+		}
+		c.add(path, "%s is not one of %s", jsonText(v), strings.Join(literals, ", "))
+	}
+}
@@ -0,0 +1,254 @@
+package typechat
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testReview struct {
+	Confidence float64  `json:"confidence" tc:"min=0,max=1"`
+	Currency   string   `json:"currency" tc:"pattern=^[A-Z]{3}$"`
+	Priority   string   `json:"priority" tc:"oneof=low medium high"`
+	Summary    string   `json:"summary" tc:"maxlen=10"`
+	Tags       []string `json:"tags,omitempty" tc:"minlen=1,maxlen=3"`
+	Stars      int      `json:"stars,string" tc:"oneof=1 2 3 4 5"`
+}
+
+func TestConstraints(t *testing.T) {
+	t.Run("it describes constraints in Go definitions", func(t *testing.T) {
+		_, def, err := structDef(reflect.TypeOf(testReview{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `
+type testReview struct {
+	Confidence float64 ` + "`json:\"confidence\"`" + ` // minimum 0, maximum 1
+	Currency string ` + "`json:\"currency\"`" + ` // must match ^[A-Z]{3}$
+	Priority string ` + "`json:\"priority\"`" + ` // one of "low", "medium", "high"
+	Summary string ` + "`json:\"summary\"`" + ` // at most 10 characters
+	Tags []string ` + "`json:\"tags\"`" + ` // optional, at least 1 items, at most 3 items
+	Stars string ` + "`json:\"stars\"`" + ` // int encoded as a JSON string, one of 1, 2, 3, 4, 5
+}`
+		assertNameDefOuptut(t, def, expected)
+	})
+
+	t.Run("it writes oneof constraints as TypeScript unions", func(t *testing.T) {
+		s, _, err := structSchema(reflect.TypeOf(testReview{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `
+interface testReview {
+    confidence: number; // minimum 0, maximum 1
+    currency: string; // must match ^[A-Z]{3}$
+    priority: "low" | "medium" | "high";
+    summary: string; // at most 10 characters
+    tags?: string[]; // at least 1 items, at most 3 items
+    stars: string; // number encoded as a JSON string, one of 1, 2, 3, 4, 5
+}`
+		assertNameDefOuptut(t, tsRenderer{}.decls(s), expected)
+	})
+
+	t.Run("it adds constraints to the JSON schema", func(t *testing.T) {
+		type Order struct {
+			Quantity uint        `json:"quantity" tc:"min=1,max=99"`
+			Code     string      `json:"code" tc:"len=6"`
+			Owner    *testUserID `json:"owner" tc:"minlen=3"`
+			Status   *string     `json:"status" tc:"oneof=open closed"`
+			Stars    int         `json:"stars,string" tc:"min=1"`
+		}
+
+		schema, err := JSONSchema[Order]()
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		assertJSONSchema(t, schema, `{
+			"$defs": {
+				"testUserID": {
+					"description": "testUserID is the unique identifier of a user account.",
+					"type": "string"
+				}
+			},
+			"$schema": "https://json-schema.org/draft/2020-12/schema",
+			"additionalProperties": false,
+			"properties": {
+				"code": {"maxLength": 6, "minLength": 6, "type": "string"},
+				"owner": {
+					"allOf": [{"anyOf": [{"$ref": "#/$defs/testUserID"}, {"type": "null"}]}],
+					"minLength": 3
+				},
+				"quantity": {"maximum": 99, "minimum": 1, "type": "integer"},
+				"stars": {"type": "string"},
+				"status": {"enum": ["open", "closed", null], "type": ["string", "null"]}
+			},
+			"required": ["quantity", "code", "stars"],
+			"type": "object"
+		}`)
+	})
+
+	t.Run("it reports responses violating constraints", func(t *testing.T) {
+		_, root, err := structSchema(reflect.TypeOf(testReview{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		err = validateJSON([]byte(`{
+			"confidence": 1.5,
+			"currency": "usd",
+			"priority": "urgent",
+			"summary": "far too long for a summary",
+			"tags": [],
+			"stars": "6"
+		}`), root)
+		assertViolations(t, err,
+			"$.confidence: 1.5 is greater than the maximum 1",
+			`$.currency: "usd" does not match ^[A-Z]{3}$`,
+			`$.priority: "urgent" is not one of "low", "medium", "high"`,
+			"$.summary: has 26 characters, expected at most 10",
+			"$.tags: has 0 items, expected at least 1",
+			`$.stars: 6 is not one of 1, 2, 3, 4, 5`,
+		)
+
+		err = validateJSON([]byte(`{
+			"confidence": 0,
+			"currency": "EUR",
+			"priority": "low",
+			"summary": "héllo",
+			"stars": "5"
+		}`), root)
+		assertViolations(t, err)
+	})
+
+	t.Run("it checks the length of null slices and maps", func(t *testing.T) {
+		type Foo struct {
+			Tags   []string          `json:"tags" tc:"minlen=1"`
+			Pair   map[string]int    `json:"pair" tc:"len=2"`
+			Labels *[]string         `json:"labels" tc:"minlen=1"`
+			Counts map[string]string `json:"counts" tc:"maxlen=1"`
+		}
+		_, root, err := structSchema(reflect.TypeOf(Foo{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		err = validateJSON([]byte(`{"tags": null, "pair": null, "labels": null, "counts": null}`), root)
+		assertViolations(t, err,
+			"$.tags: has 0 items, expected at least 1",
+			"$.pair: has 0 entries, expected exactly 2",
+		)
+	})
+
+	t.Run("it only checks constraints on values of the right type", func(t *testing.T) {
+		_, root, err := structSchema(reflect.TypeOf(testReview{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		err = validateJSON([]byte(`{
+			"confidence": "high",
+			"currency": "EUR",
+			"priority": "low",
+			"summary": "ok",
+			"stars": "1"
+		}`), root)
+		assertViolations(t, err, "$.confidence: expected a number, got a string")
+	})
+
+	t.Run("it rejects invalid constraints", func(t *testing.T) {
+		tests := []struct {
+			typ      any
+			expected string
+		}{
+			{struct {
+				A string `tc:"min=1"`
+			}{}, "field A: constraint min only applies to numbers"},
+			{struct {
+				A int `tc:"max=high"`
+			}{}, `field A: constraint max: "high" is not a number`},
+			{struct {
+				A float64 `tc:"max=.5"`
+			}{}, `field A: constraint max: ".5" is not a JSON number`},
+			{struct {
+				A float64 `tc:"min=Inf"`
+			}{}, `field A: constraint min: "Inf" is not a JSON number`},
+			{struct {
+				A int `tc:"oneof=+1 2"`
+			}{}, `field A: constraint oneof: "+1" is not a JSON number`},
+			{struct {
+				A int `tc:"min=2,max=1"`
+			}{}, "field A: constraint min 2 is greater than max 1"},
+			{struct {
+				A bool `tc:"len=1"`
+			}{}, "field A: constraint len only applies to strings, slices and maps"},
+			{struct {
+				A string `tc:"pattern=("`
+			}{}, "field A: constraint pattern: error parsing regexp: missing closing ): `(`"},
+			{struct {
+				A int `tc:"oneof=1 two"`
+			}{}, `field A: constraint oneof: "two" is not a number`},
+			{struct {
+				A string `tc:"required"`
+			}{}, `field A: unknown constraint "required"`},
+		}
+
+		for _, test := range tests {
+			_, _, err := structSchema(reflect.TypeOf(test.typ))
+			if err == nil {
+				t.Errorf("expected err to be %q, got nil", test.expected)
+				continue
+			}
+			if err.Error() != test.expected {
+				t.Errorf("expected err to be %q, got %q", test.expected, err)
+			}
+		}
+	})
+
+	t.Run("it allows commas in patterns", func(t *testing.T) {
+		type Code struct {
+			Code string `json:"code" tc:"pattern=^[A-Z]{2,3}$,maxlen=3"`
+		}
+
+		_, root, err := structSchema(reflect.TypeOf(Code{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		f := root.fields[0]
+		if f.constraints.pattern.String() != "^[A-Z]{2,3}$" {
+			t.Errorf("expected pattern to be ^[A-Z]{2,3}$, got %s", f.constraints.pattern)
+		}
+		if f.constraints.maxLen == nil || *f.constraints.maxLen != 3 {
+			t.Errorf("expected maxlen to be 3, got %v", f.constraints.maxLen)
+		}
+	})
+
+	t.Run("it sends constraint violations to the model for repair", func(t *testing.T) {
+		type Score struct {
+			Value int `json:"value" tc:"min=0,max=10"`
+		}
+
+		var prompts [][]Message
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			prompts = append(prompts, prompt)
+			return `{"value": 11}`, nil
+		})
+
+		p := NewPrompt[Score](m, "", PromptRetries[Score](1))
+		if _, err := p.Execute(context.Background()); err == nil {
+			t.Fatal("expected err to be non-nil")
+		}
+		if len(prompts) != 2 {
+			t.Fatalf("expected 2 prompts, got %d", len(prompts))
+		}
+
+		repair := prompts[1][len(prompts[1])-1].Content
+		if !strings.Contains(repair, "$.value: 11 is greater than the maximum 10") {
+			t.Errorf("expected repair prompt to contain the violation, got %s", repair)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package typechat
+
+import (
+	"reflect"
+)
+
+// Schema describes the JSON encoding of a type to the model. It is returned by types implementing SchemaProvider,
+// usually because they implement json.Marshaler and their encoding cannot be derived by reflection.
+type Schema struct {
+	// Like is a value of a type whose JSON encoding is the same as the described type, such as "" for a type encoded
+	// as a string or a struct with the same properties. The described type is declared with the structure of Like
+	// but keeps its own name. A nil Like allows any JSON value.
+	Like any
+
+	// Description is written as a comment before the declaration of the type.
+	Description string
+}
+
+// SchemaProvider is implemented by types that describe their own schema. TypeChatSchema is consulted before
+// reflecting over the type, so it takes precedence over the struct fields and well-known types.
+type SchemaProvider interface {
+	TypeChatSchema() Schema
+}
+
+var schemaProviderInterface = reflect.TypeOf((*SchemaProvider)(nil)).Elem()
+
+// customSchema returns the schema t provides for itself, if any.
+func customSchema(t reflect.Type) (Schema, bool) {
+	if t.Kind() == reflect.Interface || t.Kind() == reflect.Pointer {
+		return Schema{}, false
+	}
+
+	switch {
+	case t.Implements(schemaProviderInterface):
+		return reflect.Zero(t).Interface().(SchemaProvider).TypeChatSchema(), true
+	case reflect.PointerTo(t).Implements(schemaProviderInterface):
+		return reflect.New(t).Interface().(SchemaProvider).TypeChatSchema(), true
+	}
+
+	return Schema{}, false
+}
@@ -0,0 +1,103 @@
+package typechat
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// testMoney is encoded as a string such as "12.34 USD".
+type testMoney struct {
+	cents    int64
+	currency string
+}
+
+func (m testMoney) MarshalJSON() ([]byte, error) {
+	return json.Marshal("0.00 USD")
+}
+
+func (testMoney) TypeChatSchema() Schema {
+	return Schema{
+		Like:        "",
+		Description: `testMoney is an amount followed by a currency code, such as "12.34 USD".`,
+	}
+}
+
+// testRange is encoded as a two element array.
+type testRange struct {
+	low, high int
+}
+
+func (testRange) TypeChatSchema() Schema {
+	return Schema{Like: [2]int{}}
+}
+
+// testPoint is encoded with abbreviated property names.
+type testPoint struct {
+	X, Y float64
+}
+
+func (testPoint) TypeChatSchema() Schema {
+	return Schema{
+		Like: struct {
+			X float64 `json:"x"`
+			Y float64 `json:"y"`
+		}{},
+	}
+}
+
+type testAnything struct{}
+
+func (testAnything) TypeChatSchema() Schema {
+	return Schema{Description: "testAnything is any JSON value."}
+}
+
+type testSelf string
+
+func (testSelf) TypeChatSchema() Schema {
+	return Schema{Like: testSelf("")}
+}
+
+func TestCustomSchema(t *testing.T) {
+	t.Run("it uses the schema provided by the type", func(t *testing.T) {
+		type Foo struct {
+			Price testMoney
+			Range testRange
+			Point *testPoint
+			Extra testAnything
+		}
+
+		_, def, err := structDef(reflect.TypeOf(Foo{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `
+// testMoney is an amount followed by a currency code, such as "12.34 USD".
+type testMoney string
+type testRange []int
+type testPoint struct {
+	X float64 ` + "`" + `json:"x"` + "`" + `
+	Y float64 ` + "`" + `json:"y"` + "`" + `
+}
+// testAnything is any JSON value.
+type testAnything interface{}
+type Foo struct {
+	Price testMoney
+	Range testRange
+	Point *testPoint // optional
+	Extra testAnything
+}`
+		assertNameDefOuptut(t, def, expected)
+	})
+
+	t.Run("it rejects types described by themselves", func(t *testing.T) {
+		type Foo struct {
+			Self testSelf
+		}
+
+		if _, _, err := structDef(reflect.TypeOf(Foo{})); err == nil {
+			t.Fatal("expected err to be non-nil")
+		}
+	})
+}
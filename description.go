@@ -0,0 +1,107 @@
+package typechat
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// TypeDescriber is implemented by named types that describe their meaning to the model. The description is written
+// as a comment before the type declaration in the schema.
+type TypeDescriber interface {
+	TypeDescription() string
+}
+
+var describerInterface = reflect.TypeOf((*TypeDescriber)(nil)).Elem()
+
+var descriptions = struct {
+	sync.RWMutex
+	values map[reflect.Type]string
+}{values: make(map[reflect.Type]string)}
+
+// RegisterDescription sets the description of the named type T. It is meant for types that cannot implement
+// TypeDescriber, registered descriptions take precedence over the TypeDescription method.
+func RegisterDescription[T any](description string) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+
+	descriptions.Lock()
+	defer descriptions.Unlock()
+	descriptions.values[t] = description
+}
+
+// typeDescription returns the description of t, or an empty string if it has none.
+func typeDescription(t reflect.Type) string {
+	if t.Name() == "" || t.Kind() == reflect.Interface || t.Kind() == reflect.Pointer {
+		return ""
+	}
+
+	descriptions.RLock()
+	description, ok := descriptions.values[t]
+	descriptions.RUnlock()
+	if ok {
+		return description
+	}
+
+	switch {
+	case t.Implements(describerInterface):
+		return reflect.Zero(t).Interface().(TypeDescriber).TypeDescription()
+	case reflect.PointerTo(t).Implements(describerInterface):
+		return reflect.New(t).Interface().(TypeDescriber).TypeDescription()
+	}
+
+	return ""
+}
+
+// Param names and describes a parameter of an API method.
+type Param struct {
+	Name        string
+	Description string
+}
+
+// methodDoc documents a method of an API interface.
+type methodDoc struct {
+	description string
+	params      []Param
+}
+
+var methodDocs = struct {
+	sync.RWMutex
+	values map[reflect.Type]map[string]methodDoc
+}{values: make(map[reflect.Type]map[string]methodDoc)}
+
+// RegisterMethod sets the description of a method of the API interface T and the names and descriptions of its
+// parameters, which reflection cannot see. params are given in order, leaving out a leading context.Context, and may
+// stop before the last parameter. Parameters without a name are named after their position. Programs for T fail to be
+// created if a registered method is not part of T, such as a misspelled one.
+func RegisterMethod[T any](method, description string, params ...Param) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+
+	methodDocs.Lock()
+	defer methodDocs.Unlock()
+	if methodDocs.values[t] == nil {
+		methodDocs.values[t] = make(map[string]methodDoc)
+	}
+	methodDocs.values[t][method] = methodDoc{description: description, params: params}
+}
+
+// methodDescription returns the documentation registered for a method of the API interface t.
+func methodDescription(t reflect.Type, method string) methodDoc {
+	methodDocs.RLock()
+	defer methodDocs.RUnlock()
+
+	return methodDocs.values[t][method]
+}
+
+// registeredMethods returns the sorted names of the methods documented for the API interface t.
+func registeredMethods(t reflect.Type) []string {
+	methodDocs.RLock()
+	defer methodDocs.RUnlock()
+
+	var names []string
+	for name := range methodDocs.values[t] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,154 @@
+package typechat
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Enum is implemented by named types whose values are restricted to a fixed set. The allowed values are listed in
+// the schema shown to the model and responses containing any other value are sent back for repair.
+type Enum interface {
+	EnumValues() []any
+}
+
+var enumInterface = reflect.TypeOf((*Enum)(nil)).Elem()
+
+var enums = struct {
+	sync.RWMutex
+	values map[reflect.Type][]any
+}{values: make(map[reflect.Type][]any)}
+
+// RegisterEnum declares the values allowed for the named type T. It is meant for types that cannot implement Enum,
+// such as types from other packages. Registered values take precedence over the EnumValues method.
+func RegisterEnum[T any](values ...T) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+
+	vs := make([]any, len(values))
+	for i, v := range values {
+		vs[i] = v
+	}
+
+	enums.Lock()
+	defer enums.Unlock()
+	enums.values[t] = vs
+}
+
+// enumValues returns the values allowed for t, or nil if t is not an enum. Only named scalar types can be enums.
+func enumValues(t reflect.Type) ([]any, error) {
+	_, custom := customSchema(t)
+	if _, wellKnown := lookupWellKnown(t); !custom && !wellKnown && !namedScalar(t) {
+		return nil, nil
+	}
+
+	enums.RLock()
+	values, ok := enums.values[t]
+	enums.RUnlock()
+	if ok {
+		return values, nil
+	}
+
+	switch {
+	case t.Implements(enumInterface):
+		values = reflect.Zero(t).Interface().(Enum).EnumValues()
+	case reflect.PointerTo(t).Implements(enumInterface):
+		values = reflect.New(t).Interface().(Enum).EnumValues()
+	default:
+		return nil, nil
+	}
+
+	converted := make([]any, 0, len(values))
+	for _, v := range values {
+		c, err := enumValue(v, t)
+		if err != nil {
+			return nil, err
+		}
+		converted = append(converted, c)
+	}
+
+	return converted, nil
+}
+
+// enumValue converts a value returned by EnumValues to t. EnumValues may return untyped constants such as "low" for a
+// named string type, so values of a predeclared type are converted as long as they are the same kind of value as t
+// and convert without loss.
+func enumValue(v any, t reflect.Type) (any, error) {
+	rv := reflect.ValueOf(v)
+	switch {
+	case !rv.IsValid():
+		return nil, fmt.Errorf("value nil is not a %s", t)
+	case rv.Type() == t:
+		return v, nil
+	case rv.Type().PkgPath() != "" || rv.Type().Name() == "" || valueClass(t.Kind()) == "" ||
+		valueClass(rv.Kind()) != valueClass(t.Kind()):
+		return nil, fmt.Errorf("value %v of type %s is not a %s", v, rv.Type(), t)
+	}
+
+	c := rv.Convert(t)
+	if c.Convert(rv.Type()).Interface() != v {
+		return nil, fmt.Errorf("value %v does not fit in %s", v, t)
+	}
+
+	return c.Interface(), nil
+}
+
+// valueClass groups the scalar kinds whose values convert into each other without changing their meaning.
+func valueClass(k reflect.Kind) string {
+	switch {
+	case k == reflect.String:
+		return "string"
+	case k == reflect.Bool:
+		return "bool"
+	case intKind(k) || uintKind(k) || k == reflect.Float32 || k == reflect.Float64:
+		return "number"
+	}
+
+	return ""
+}
+
+// enumLiteral is an enum value encoded as JSON, with the result of its String method when that adds information.
+type enumLiteral struct {
+	value string
+	label string
+}
+
+func (l enumLiteral) String() string {
+	if l.label == "" {
+		return l.value
+	}
+
+	return fmt.Sprintf("%s (%s)", l.value, l.label)
+}
+
+// enumDecl returns the JSON literals of the values allowed for t, or nil if t is not an enum. They are computed once,
+// when t is declared, so encoding errors are reported there.
+func enumDecl(t reflect.Type) ([]enumLiteral, error) {
+	values, err := enumValues(t)
+	if err != nil || values == nil {
+		return nil, err
+	}
+
+	return enumLiterals(values)
+}
+
+func enumLiterals(values []any) ([]enumLiteral, error) {
+	literals := make([]enumLiteral, 0, len(values))
+	for _, v := range values {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		literal := enumLiteral{value: string(b)}
+		if s, ok := v.(fmt.Stringer); ok {
+			if label := s.String(); label != "" && label != strings.Trim(literal.value, `"`) {
+				literal.label = label
+			}
+		}
+		literals = append(literals, literal)
+	}
+
+	return literals, nil
+}
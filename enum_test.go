@@ -0,0 +1,165 @@
+package typechat
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testSentiment int
+
+const (
+	testPositive testSentiment = iota
+	testNegative
+	testNeutral
+)
+
+func (s testSentiment) String() string {
+	switch s {
+	case testPositive:
+		return "positive"
+	case testNegative:
+		return "negative"
+	case testNeutral:
+		return "neutral"
+	}
+
+	return ""
+}
+
+func (testSentiment) EnumValues() []any {
+	return []any{testPositive, testNegative, testNeutral}
+}
+
+type testPriority string
+
+type testMood string
+
+func (testMood) EnumValues() []any {
+	return []any{"calm", "angry"}
+}
+
+type testStringLevel int
+
+func (testStringLevel) EnumValues() []any {
+	return []any{"low", "high"}
+}
+
+type testRuneGrade string
+
+func (testRuneGrade) EnumValues() []any {
+	return []any{65, 66}
+}
+
+type testByteLevel uint8
+
+func (testByteLevel) EnumValues() []any {
+	return []any{1, 300}
+}
+
+func TestEnum(t *testing.T) {
+	RegisterEnum[testPriority]("low", "medium", "high")
+
+	t.Run("it declares enum types with their allowed values", func(t *testing.T) {
+		type Foo struct {
+			Sentiment testSentiment
+			Priority  []testPriority
+		}
+
+		_, def, err := structDef(reflect.TypeOf(Foo{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `
+// testSentiment must be one of: 0 (positive), 1 (negative), 2 (neutral)
+type testSentiment int
+// testPriority must be one of: "low", "medium", "high"
+type testPriority string
+type Foo struct {
+	Sentiment testSentiment
+	Priority []testPriority
+}`
+		assertNameDefOuptut(t, def, expected)
+	})
+
+	t.Run("it converts untyped enum values", func(t *testing.T) {
+		values, err := enumValues(reflect.TypeOf(testSentiment(0)))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if len(values) != 3 || values[1] != testNegative {
+			t.Errorf("expected sentiment values, got %v", values)
+		}
+	})
+
+	t.Run("it converts untyped constants of the same kind", func(t *testing.T) {
+		values, err := enumValues(reflect.TypeOf(testMood("")))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if !reflect.DeepEqual(values, []any{testMood("calm"), testMood("angry")}) {
+			t.Errorf("expected mood values, got %#v", values)
+		}
+	})
+
+	t.Run("it rejects enum values that are not of the enum type", func(t *testing.T) {
+		tests := []struct {
+			typ      reflect.Type
+			expected string
+		}{
+			{reflect.TypeOf(testStringLevel(0)), "enum testStringLevel: value low of type string is not a typechat.testStringLevel"},
+			{reflect.TypeOf(testRuneGrade("")), "enum testRuneGrade: value 65 of type int is not a typechat.testRuneGrade"},
+			{reflect.TypeOf(testByteLevel(0)), "enum testByteLevel: value 300 does not fit in typechat.testByteLevel"},
+		}
+
+		for _, test := range tests {
+			_, err := newSchema().declare(test.typ)
+			if err == nil || err.Error() != test.expected {
+				t.Errorf("expected err to be %q, got %v", test.expected, err)
+			}
+		}
+	})
+
+	t.Run("it reports values outside of the enum", func(t *testing.T) {
+		type Foo struct {
+			Sentiment testSentiment            `json:"sentiment"`
+			Items     []testPriority           `json:"items"`
+			ByName    map[string]*testPriority `json:"by_name"`
+		}
+
+		_, root, err := structSchema(reflect.TypeOf(Foo{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		err = validateJSON([]byte(`{"sentiment": 4, "items": ["low", "none"], "by_name": {"a": "urgent"}}`), root)
+		if err == nil {
+			t.Fatal("expected err to be non-nil")
+		}
+
+		expected := []string{
+			`$.sentiment: 4 is not one of 0 (positive), 1 (negative), 2 (neutral)`,
+			`$.items[1]: "none" is not one of "low", "medium", "high"`,
+			`$.by_name["a"]: "urgent" is not one of "low", "medium", "high"`,
+		}
+		if err.Error() != strings.Join(expected, "\n") {
+			t.Errorf("expected err to be:\n%s\ngot:\n%s", strings.Join(expected, "\n"), err)
+		}
+	})
+
+	t.Run("it fails to execute prompts with invalid enum values", func(t *testing.T) {
+		type Result struct {
+			Sentiment testSentiment
+		}
+
+		m := mockModelClient{
+			response: `{"Sentiment": 7}`,
+		}
+		p := NewPrompt[Result](m, "")
+		if _, err := p.Execute(context.Background()); err == nil {
+			t.Fatal("expected err to be non-nil")
+		}
+	})
+}
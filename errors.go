@@ -0,0 +1,66 @@
+package typechat
+
+import (
+	"fmt"
+)
+
+// TransportError is returned when the model client fails, such as when the provider cannot be reached or rejects the
+// request.
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("model request failed: %s", e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError is returned when a response cannot be parsed into the expected type, because it holds no JSON, the JSON
+// is invalid or it does not match the schema.
+type ParseError struct {
+	Response string
+	Err      error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse response: %s", e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError is returned when a parsed response is rejected by the Validate method of the result type or by the
+// validators of the prompt.
+type ValidationError struct {
+	Response string
+	Err      error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid response: %s", e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// RetriesExhaustedError is returned when the model did not produce a valid response within the repairs allowed by
+// PromptRetries. Errors holds the *ParseError or *ValidationError of every attempt, the last one included, and is
+// searched by errors.Is and errors.As.
+type RetriesExhaustedError struct {
+	Retries      int
+	LastResponse string
+	Errors       []error
+}
+
+func (e *RetriesExhaustedError) Error() string {
+	return fmt.Sprintf("no valid response after %d repairs: %s", e.Retries, e.Errors[len(e.Errors)-1])
+}
+
+func (e *RetriesExhaustedError) Unwrap() []error {
+	return e.Errors
+}
@@ -0,0 +1,103 @@
+package typechat
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	type Result struct {
+		Sentiment string `json:"sentiment"`
+	}
+
+	t.Run("it wraps model client errors", func(t *testing.T) {
+		cause := errors.New("connection refused")
+		m := mockModelClient{err: cause}
+
+		_, err := NewPrompt[Result](m, "").Execute(context.Background())
+		var transportErr *TransportError
+		if !errors.As(err, &transportErr) {
+			t.Fatalf("expected a TransportError, got %v", err)
+		}
+		if !errors.Is(err, cause) {
+			t.Errorf("expected err to wrap %v, got %v", cause, err)
+		}
+
+		var exhaustedErr *RetriesExhaustedError
+		if errors.As(err, &exhaustedErr) {
+			t.Error("expected transport errors not to be retried as parse errors")
+		}
+	})
+
+	t.Run("it returns every attempt error once the retries are exhausted", func(t *testing.T) {
+		responses := []string{`no JSON here`, `{"sentiment": 1}`}
+		var calls int
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			calls++
+			return responses[calls-1], nil
+		})
+
+		_, err := NewPrompt[Result](m, "").Execute(context.Background())
+		var exhaustedErr *RetriesExhaustedError
+		if !errors.As(err, &exhaustedErr) {
+			t.Fatalf("expected a RetriesExhaustedError, got %v", err)
+		}
+		if exhaustedErr.Retries != 1 {
+			t.Errorf("expected 1 retry, got %d", exhaustedErr.Retries)
+		}
+		if exhaustedErr.LastResponse != `{"sentiment": 1}` {
+			t.Errorf("expected the last response, got %s", exhaustedErr.LastResponse)
+		}
+		if len(exhaustedErr.Errors) != 2 {
+			t.Fatalf("expected 2 errors, got %d", len(exhaustedErr.Errors))
+		}
+
+		var parseErr *ParseError
+		if !errors.As(exhaustedErr.Errors[0], &parseErr) || parseErr.Response != "no JSON here" {
+			t.Errorf("expected a ParseError for the first response, got %v", exhaustedErr.Errors[0])
+		}
+		if !errors.Is(err, ErrNoJSON) {
+			t.Errorf("expected err to wrap ErrNoJSON, got %v", err)
+		}
+		expected := `failed to execute prompt: no valid response after 1 repairs: failed to parse response: ` +
+			`$.sentiment: expected a string, got a number`
+		if err.Error() != expected {
+			t.Errorf("expected err to be %q, got %q", expected, err)
+		}
+	})
+
+	t.Run("it separates validation errors from parse errors", func(t *testing.T) {
+		cause := errors.New("end must be after start")
+		m := mockModelClient{response: `{"start": 2, "end": 1}`}
+
+		_, err := NewPrompt[testBooking](m, "", PromptRetries[testBooking](0)).Execute(context.Background())
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Fatalf("expected a ValidationError, got %v", err)
+		}
+		if validationErr.Err.Error() != cause.Error() {
+			t.Errorf("expected %v, got %v", cause, validationErr.Err)
+		}
+
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			t.Errorf("expected no ParseError, got %v", parseErr)
+		}
+	})
+
+	t.Run("it sends the reason without the error type to the model", func(t *testing.T) {
+		var prompts [][]Message
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			prompts = append(prompts, prompt)
+			return `{"start": 2, "end": 1}`, nil
+		})
+
+		_, _ = NewPrompt[testBooking](m, "").Execute(context.Background())
+		repair := prompts[1][len(prompts[1])-1].Content
+		expected := "The JSON object is invalid for the following reason:\nend must be after start\n"
+		if repair[:len(expected)] != expected {
+			t.Errorf("expected repair prompt to start with %q, got %q", expected, repair)
+		}
+	})
+}
@@ -32,8 +32,13 @@ func (s Sentiment) String() string {
 	return ""
 }
 
+// EnumValues lists the allowed sentiments so the model can only answer with one of them.
+func (Sentiment) EnumValues() []any {
+	return []any{Positive, Negative, Neutral}
+}
+
 type SentimentAnalysis struct {
-	Sentiment  Sentiment `description:"0=positive, 1=negative, 2=neutral"`
+	Sentiment  Sentiment
 	Confidence float64
 }
 
@@ -0,0 +1,313 @@
+package typechat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// ErrorPolicy decides whether a program keeps running after one of its steps fails.
+type ErrorPolicy struct {
+	name string
+}
+
+func (p ErrorPolicy) String() string {
+	return p.name
+}
+
+var (
+	// StopOnError stops a program at the first step that fails. It is the default policy.
+	StopOnError = ErrorPolicy{name: "stop"}
+	// ContinueOnError runs every step of a program, even after a step fails.
+	ContinueOnError = ErrorPolicy{name: "continue"}
+)
+
+// StepResult is the outcome of one step of a program.
+type StepResult struct {
+	Name string
+	// Results are the values returned by the method, without its trailing error.
+	Results []any
+	Err     error
+}
+
+// StepError is returned for a step of a program that could not be run or whose method returned an error.
+type StepError struct {
+	Step int
+	Name string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step %d (%s): %s", e.Step, e.Name, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// Executor runs programs created by Prompt.CreateProgram by calling the methods of an implementation of the API
+// interface T.
+type Executor[T any] struct {
+	impl   T
+	policy ErrorPolicy
+}
+
+type executorOpt[T any] func(*Executor[T])
+
+// ExecutorErrorPolicy sets whether programs keep running after a step fails, defaults to StopOnError.
+func ExecutorErrorPolicy[T any](policy ErrorPolicy) executorOpt[T] {
+	return func(e *Executor[T]) {
+		e.policy = policy
+	}
+}
+
+// NewExecutor creates a new Executor[T] calling the methods of impl.
+func NewExecutor[T any](impl T, opts ...executorOpt[T]) *Executor[T] {
+	e := &Executor[T]{
+		impl:   impl,
+		policy: StopOnError,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Run calls the methods named by the steps of program in order, converting the JSON arguments of each step to the
+// types of the method parameters. Methods whose first parameter is a context.Context are passed ctx. Run returns the
+// results of the steps that ran and the errors of the steps that failed, joined together.
+func (e *Executor[T]) Run(ctx context.Context, program Program) ([]StepResult, error) {
+	var results []StepResult
+	var errs []error
+	for i, call := range program.Steps {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		values, err := e.call(ctx, call, results)
+		r := StepResult{Name: call.Name, Results: values}
+		if err != nil {
+			r.Err = &StepError{Step: i, Name: call.Name, Err: err}
+			errs = append(errs, r.Err)
+		}
+		results = append(results, r)
+
+		if err != nil && e.policy != ContinueOnError {
+			break
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// call invokes the method of a step. done holds the results of the steps before it, for resolving references.
+func (e *Executor[T]) call(ctx context.Context, call FunctionCall, done []StepResult) ([]any, error) {
+	impl := reflect.ValueOf(e.impl)
+	if !impl.IsValid() {
+		return nil, errors.New("no API implementation")
+	}
+
+	api := reflect.TypeOf((*T)(nil)).Elem()
+	if _, ok := api.MethodByName(call.Name); api.Kind() == reflect.Interface && !ok {
+		return nil, fmt.Errorf("unknown method %s", call.Name)
+	}
+	method := impl.MethodByName(call.Name)
+	if !method.IsValid() {
+		return nil, fmt.Errorf("unknown method %s", call.Name)
+	}
+
+	params, withContext := apiParams(method.Type())
+	if len(call.Args) != len(params) {
+		return nil, fmt.Errorf("expected %d arguments, got %d", len(params), len(call.Args))
+	}
+
+	var in []reflect.Value
+	if withContext {
+		in = append(in, reflect.ValueOf(ctx))
+	}
+	for i, arg := range call.Args {
+		v, err := resolveArg(arg, params[i], done)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+		in = append(in, v)
+	}
+
+	var out []reflect.Value
+	if method.Type().IsVariadic() {
+		out = method.CallSlice(in)
+	} else {
+		out = method.Call(in)
+	}
+
+	var results []any
+	var err error
+	for i, v := range out {
+		if i == len(out)-1 && method.Type().Out(i) == errorType {
+			err, _ = v.Interface().(error)
+			continue
+		}
+		results = append(results, v.Interface())
+	}
+
+	return results, err
+}
+
+// apiParams returns the parameters of an API method the model provides arguments for, leaving out a leading
+// context.Context.
+func apiParams(fn reflect.Type) ([]reflect.Type, bool) {
+	var params []reflect.Type
+	withContext := fn.NumIn() > 0 && fn.In(0) == contextType
+	for i := 0; i < fn.NumIn(); i++ {
+		if i == 0 && withContext {
+			continue
+		}
+		params = append(params, fn.In(i))
+	}
+
+	return params, withContext
+}
+
+// resolveArg converts a decoded JSON argument to the type t of a method parameter, replacing references with the
+// results of earlier steps. A reference must return the type of the parameter, or of the element, map value or field
+// it is passed as.
+func resolveArg(arg any, t reflect.Type, done []StepResult) (reflect.Value, error) {
+	if r, ok := arg.(Ref); ok {
+		result, err := refResult(r, done, t)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		if result == nil {
+			return reflect.Zero(t), nil
+		}
+		return reflect.ValueOf(result), nil
+	}
+
+	arg, err := substituteRefs(arg, t, done)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	return convertArg(arg, t)
+}
+
+// substituteRefs replaces the references nested in a decoded JSON value with the results they refer to. t is the type
+// the value is decoded into, or nil if it is not known.
+func substituteRefs(v any, t reflect.Type, done []StepResult) (any, error) {
+	switch v := v.(type) {
+	case Ref:
+		if t == nil {
+			return nil, fmt.Errorf("reference to step %d where the argument cannot hold a value", v.Step)
+		}
+		return refResult(v, done, t)
+	case []any:
+		elem := containedType(t, "")
+		out := make([]any, len(v))
+		for i, item := range v {
+			item, err := substituteRefs(item, elem, done)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = item
+		}
+		return out, nil
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			value, err := substituteRefs(value, containedType(t, key), done)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = value
+		}
+		return out, nil
+	}
+
+	return v, nil
+}
+
+// containedType returns the type of the elements of a slice or array type t, or of the values of a map type t, or of
+// the field of a struct type t with the JSON name key. Values inside an interface type can have any type. It returns
+// nil if t has no such values.
+func containedType(t reflect.Type, key string) reflect.Type {
+	if t == nil {
+		return nil
+	}
+
+	t, _ = indirect(t)
+	switch t.Kind() {
+	case reflect.Interface:
+		return t
+	case reflect.Slice, reflect.Array:
+		if key == "" {
+			return t.Elem()
+		}
+	case reflect.Map:
+		if key != "" {
+			return t.Elem()
+		}
+	case reflect.Struct:
+		var folded reflect.Type
+		for _, f := range structFields(t) {
+			switch {
+			case f.name == key:
+				return f.field.Type
+			case folded == nil && strings.EqualFold(f.name, key):
+				// encoding/json matches names case-insensitively when there is no exact match
+				folded = f.field.Type
+			}
+		}
+		return folded
+	}
+
+	return nil
+}
+
+// refResult returns the result of the step r refers to, which must have run successfully and returned one value
+// assignable to t.
+func refResult(r Ref, done []StepResult, t reflect.Type) (any, error) {
+	switch {
+	case r.Step < 0:
+		return nil, fmt.Errorf("reference to step %d, which does not exist", r.Step)
+	case r.Step >= len(done):
+		return nil, fmt.Errorf("reference to step %d, which has not run yet", r.Step)
+	}
+
+	step := done[r.Step]
+	switch {
+	case step.Err != nil:
+		return nil, fmt.Errorf("reference to step %d, which failed", r.Step)
+	case len(step.Results) != 1:
+		return nil, fmt.Errorf("reference to step %d, which returns %d values instead of 1", r.Step, len(step.Results))
+	}
+
+	result := step.Results[0]
+	if v := reflect.ValueOf(result); v.IsValid() && !v.Type().AssignableTo(t) {
+		return nil, fmt.Errorf("step %d returns %s, expected %s", r.Step, v.Type(), t)
+	}
+
+	return result, nil
+}
+
+// convertArg converts a decoded JSON argument to the type t of a method parameter.
+func convertArg(arg any, t reflect.Type) (reflect.Value, error) {
+	data, err := json.Marshal(arg)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	v := reflect.New(t)
+	if err := json.Unmarshal(data, v.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+
+	return v.Elem(), nil
+}
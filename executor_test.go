@@ -0,0 +1,318 @@
+package typechat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testSocialAPI interface {
+	Post(message string) (int, error)
+	Tag(postID int, tags []string)
+	Schedule(ctx context.Context, postID int, delay float64) error
+	Count() int
+}
+
+type testSocial struct {
+	posts    []string
+	tags     map[int][]string
+	ctxValue any
+	failPost bool
+}
+
+func (s *testSocial) Post(message string) (int, error) {
+	if s.failPost {
+		return 0, errors.New("rate limited")
+	}
+	s.posts = append(s.posts, message)
+	return len(s.posts) - 1, nil
+}
+
+func (s *testSocial) Tag(postID int, tags []string) {
+	if s.tags == nil {
+		s.tags = map[int][]string{}
+	}
+	s.tags[postID] = tags
+}
+
+type testContextKey struct{}
+
+func (s *testSocial) Schedule(ctx context.Context, postID int, delay float64) error {
+	s.ctxValue = ctx.Value(testContextKey{})
+	return nil
+}
+
+func (s *testSocial) Count() int {
+	return len(s.posts)
+}
+
+// Delete is not part of testSocialAPI.
+func (s *testSocial) Delete(postID int) {}
+
+func TestExecutor(t *testing.T) {
+	t.Run("it calls the methods of each step", func(t *testing.T) {
+		impl := &testSocial{}
+		program := Program{Steps: []FunctionCall{
+			{Name: "Post", Args: []any{"hello"}},
+			{Name: "Tag", Args: []any{float64(0), []any{"greeting", "news"}}},
+			{Name: "Count"},
+		}}
+
+		results, err := NewExecutor[testSocialAPI](impl).Run(context.Background(), program)
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := []StepResult{
+			{Name: "Post", Results: []any{0}},
+			{Name: "Tag"},
+			{Name: "Count", Results: []any{1}},
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Errorf("expected results %+v, got %+v", expected, results)
+		}
+		if !reflect.DeepEqual(impl.tags[0], []string{"greeting", "news"}) {
+			t.Errorf("expected post 0 to be tagged, got %v", impl.tags)
+		}
+	})
+
+	t.Run("it passes the context to methods accepting one", func(t *testing.T) {
+		impl := &testSocial{}
+		ctx := context.WithValue(context.Background(), testContextKey{}, "value")
+		program := Program{Steps: []FunctionCall{{Name: "Schedule", Args: []any{float64(0), 1.5}}}}
+
+		if _, err := NewExecutor[testSocialAPI](impl).Run(ctx, program); err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if impl.ctxValue != "value" {
+			t.Errorf("expected the context to be passed, got %v", impl.ctxValue)
+		}
+	})
+
+	t.Run("it reports steps that cannot be called", func(t *testing.T) {
+		tests := []struct {
+			call     FunctionCall
+			expected string
+		}{
+			{FunctionCall{Name: "Publish"}, "step 0 (Publish): unknown method Publish"},
+			{FunctionCall{Name: "Delete", Args: []any{float64(0)}}, "step 0 (Delete): unknown method Delete"},
+			{FunctionCall{Name: "Post"}, "step 0 (Post): expected 1 arguments, got 0"},
+			{
+				FunctionCall{Name: "Tag", Args: []any{"first", []any{}}},
+				"step 0 (Tag): argument 0: json: cannot unmarshal string into",
+			},
+		}
+
+		for _, test := range tests {
+			program := Program{Steps: []FunctionCall{test.call}}
+			_, err := NewExecutor[testSocialAPI](&testSocial{}).Run(context.Background(), program)
+
+			var stepErr *StepError
+			if !errors.As(err, &stepErr) {
+				t.Errorf("expected a StepError, got %v", err)
+				continue
+			}
+			if !strings.HasPrefix(err.Error(), test.expected) {
+				t.Errorf("expected err to start with %q, got %q", test.expected, err)
+			}
+		}
+	})
+
+	t.Run("it stops at the first failing step by default", func(t *testing.T) {
+		impl := &testSocial{failPost: true}
+		program := Program{Steps: []FunctionCall{
+			{Name: "Post", Args: []any{"hello"}},
+			{Name: "Count"},
+		}}
+
+		results, err := NewExecutor[testSocialAPI](impl).Run(context.Background(), program)
+		if err == nil || err.Error() != "step 0 (Post): rate limited" {
+			t.Errorf("expected the error of the first step, got %v", err)
+		}
+		if len(results) != 1 || results[0].Err == nil {
+			t.Errorf("expected only the failed step, got %+v", results)
+		}
+	})
+
+	t.Run("it keeps going when the policy allows it", func(t *testing.T) {
+		impl := &testSocial{failPost: true}
+		program := Program{Steps: []FunctionCall{
+			{Name: "Post", Args: []any{"hello"}},
+			{Name: "Post", Args: []any{"again"}},
+			{Name: "Count"},
+		}}
+
+		results, err := NewExecutor[testSocialAPI](impl, ExecutorErrorPolicy[testSocialAPI](ContinueOnError)).
+			Run(context.Background(), program)
+		if len(results) != 3 {
+			t.Fatalf("expected 3 results, got %d", len(results))
+		}
+		if results[2].Err != nil || results[2].Results[0] != 0 {
+			t.Errorf("expected the last step to succeed, got %+v", results[2])
+		}
+		if err == nil || strings.Count(err.Error(), "rate limited") != 2 {
+			t.Errorf("expected both errors, got %v", err)
+		}
+	})
+
+	t.Run("it stops when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		program := Program{Steps: []FunctionCall{{Name: "Count"}}}
+		results, err := NewExecutor[testSocialAPI](&testSocial{}).Run(ctx, program)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected err to be context.Canceled, got %v", err)
+		}
+		if len(results) != 0 {
+			t.Errorf("expected no results, got %+v", results)
+		}
+	})
+
+	t.Run("it leaves context parameters out of the API definitions", func(t *testing.T) {
+		api := reflect.TypeOf((*testSocialAPI)(nil)).Elem()
+
+		def, err := interfaceDef(api)
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if !strings.Contains(def, "\tSchedule(int, float64) (error)\n") {
+			t.Errorf("expected Schedule without its context, got:\n%s", def)
+		}
+
+		a, err := newAPISchema(api, newSchema())
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		def = tsRenderer{}.api(a)
+		if !strings.Contains(def, "Schedule(arg0: number, arg1: number): void;") {
+			t.Errorf("expected Schedule without its context, got:\n%s", def)
+		}
+	})
+
+	t.Run("it decodes references to earlier steps", func(t *testing.T) {
+		var program Program
+		err := json.Unmarshal([]byte(`{"Steps": [
+			{"Name": "Post", "Args": ["hello"]},
+			{"Name": "Tag", "Args": [{"@ref": 0}, [{"@ref": 0}, "news"]]},
+			{"Name": "Post", "Args": [{"text": "a", "@ref": 1}]}
+		]}`), &program)
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := []any{Ref{Step: 0}, []any{Ref{Step: 0}, "news"}}
+		if !reflect.DeepEqual(program.Steps[1].Args, expected) {
+			t.Errorf("expected args %#v, got %#v", expected, program.Steps[1].Args)
+		}
+		// objects with other properties are not references
+		if _, ok := program.Steps[2].Args[0].(map[string]any); !ok {
+			t.Errorf("expected an object, got %#v", program.Steps[2].Args[0])
+		}
+
+		data, err := json.Marshal(program.Steps[1])
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if string(data) != `{"Name":"Tag","Args":[{"@ref":0},[{"@ref":0},"news"]]}` {
+			t.Errorf("expected references to encode back, got %s", data)
+		}
+	})
+
+	t.Run("it rejects invalid references", func(t *testing.T) {
+		for _, ref := range []string{`-1`, `1.5`, `"0"`} {
+			var program Program
+			err := json.Unmarshal([]byte(`{"Steps": [{"Name": "Post", "Args": [{"@ref": `+ref+`}]}]}`), &program)
+			expected := "argument 0 of Post: invalid reference " + ref + ", expected the index of a step"
+			if err == nil || err.Error() != expected {
+				t.Errorf("expected err to be %q, got %v", expected, err)
+			}
+		}
+	})
+
+	t.Run("it passes the results of earlier steps to references", func(t *testing.T) {
+		impl := &testSocial{}
+		program := Program{Steps: []FunctionCall{
+			{Name: "Post", Args: []any{"hello"}},
+			{Name: "Post", Args: []any{"world"}},
+			{Name: "Tag", Args: []any{Ref{Step: 1}, []any{"news"}}},
+		}}
+
+		if _, err := NewExecutor[testSocialAPI](impl).Run(context.Background(), program); err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if !reflect.DeepEqual(impl.tags, map[int][]string{1: {"news"}}) {
+			t.Errorf("expected post 1 to be tagged, got %v", impl.tags)
+		}
+	})
+
+	t.Run("it checks the type of references", func(t *testing.T) {
+		tests := []struct {
+			steps    []FunctionCall
+			expected string
+		}{
+			{
+				[]FunctionCall{{Name: "Count"}, {Name: "Post", Args: []any{Ref{Step: 0}}}},
+				"step 1 (Post): argument 0: step 0 returns int, expected string",
+			},
+			{
+				[]FunctionCall{{Name: "Count"}, {Name: "Tag", Args: []any{float64(0), []any{Ref{Step: 0}}}}},
+				"step 1 (Tag): argument 1: step 0 returns int, expected string",
+			},
+			{
+				[]FunctionCall{{Name: "Count"}, {Name: "Tag", Args: []any{float64(0), []any{[]any{Ref{Step: 0}}}}}},
+				"step 1 (Tag): argument 1: reference to step 0 where the argument cannot hold a value",
+			},
+			{
+				[]FunctionCall{{Name: "Post", Args: []any{Ref{Step: 0}}}},
+				"step 0 (Post): argument 0: reference to step 0, which has not run yet",
+			},
+			{
+				[]FunctionCall{{Name: "Count"}, {Name: "Post", Args: []any{Ref{Step: -1}}}},
+				"step 1 (Post): argument 0: reference to step -1, which does not exist",
+			},
+			{
+				[]FunctionCall{{Name: "Count"}, {Name: "Tag", Args: []any{float64(0), []any{Ref{Step: -1}}}}},
+				"step 1 (Tag): argument 1: reference to step -1, which does not exist",
+			},
+			{
+				[]FunctionCall{{Name: "Tag", Args: []any{float64(0), []any{}}}, {Name: "Tag", Args: []any{Ref{Step: 0}, []any{}}}},
+				"step 1 (Tag): argument 0: reference to step 0, which returns 0 values instead of 1",
+			},
+		}
+
+		for _, test := range tests {
+			_, err := NewExecutor[testSocialAPI](&testSocial{}).Run(context.Background(), Program{Steps: test.steps})
+			if err == nil || !strings.HasPrefix(err.Error(), test.expected) {
+				t.Errorf("expected err to start with %q, got %v", test.expected, err)
+			}
+		}
+	})
+
+	t.Run("it does not resolve references to failed steps", func(t *testing.T) {
+		impl := &testSocial{failPost: true}
+		program := Program{Steps: []FunctionCall{
+			{Name: "Post", Args: []any{"hello"}},
+			{Name: "Tag", Args: []any{Ref{Step: 0}, []any{}}},
+		}}
+
+		_, err := NewExecutor[testSocialAPI](impl, ExecutorErrorPolicy[testSocialAPI](ContinueOnError)).
+			Run(context.Background(), program)
+		if err == nil || !strings.Contains(err.Error(), "step 1 (Tag): argument 0: reference to step 0, which failed") {
+			t.Errorf("expected a failed reference error, got %v", err)
+		}
+	})
+
+	t.Run("it describes references in the program prompt", func(t *testing.T) {
+		msgs, err := newProgram[testSocialAPI]("", goRenderer{}).prompt()
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if !strings.Contains(msgs[0].Content, `{"@ref": n}`) {
+			t.Errorf("expected the prompt to describe references, got:\n%s", msgs[0].Content)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package typechat
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ErrNoJSON is returned by ExtractJSON when a response does not contain a JSON object or array.
+var ErrNoJSON = errors.New("the response does not contain a JSON object or array")
+
+// Extractor finds the JSON value in the response of a model, which may surround it with prose or markdown.
+type Extractor interface {
+	Extract(response string) (string, error)
+}
+
+// ExtractorFunc is a function that implements the Extractor interface.
+type ExtractorFunc func(response string) (string, error)
+
+// Extract calls f(response).
+func (f ExtractorFunc) Extract(response string) (string, error) {
+	return f(response)
+}
+
+var codeFence = regexp.MustCompile("(?s)```[\\w-]*[ \\t]*\\n(.*?)```")
+
+// ExtractJSON is the default Extractor. It looks inside markdown code fences first and then returns the first
+// balanced JSON object of the text, leaving out any preamble or trailing prose. Responses are objects, so arrays,
+// such as a [1] citing a source, are only returned when the text has no object. When no balanced value is valid
+// JSON, the text from the first opening brace or bracket is returned, preferably from a code fence, so it can still
+// be fixed or the decoding error can describe what is wrong with it.
+func ExtractJSON(response string) (string, error) {
+	fences := codeFence.FindAllStringSubmatch(response, -1)
+	for _, open := range []byte("{[") {
+		for _, m := range fences {
+			if v, ok := balancedJSON(m[1], open); ok {
+				return v, nil
+			}
+		}
+
+		if v, ok := balancedJSON(response, open); ok {
+			return v, nil
+		}
+	}
+
+	for _, m := range fences {
+		if start := openingIndex(m[1]); start >= 0 {
+			return strings.TrimSpace(m[1][start:]), nil
+		}
+	}
+
+	start := openingIndex(response)
+	if start < 0 {
+		return "", ErrNoJSON
+	}
+
+	return strings.TrimSpace(response[start:]), nil
+}
+
+// openingIndex returns the index of the first opening brace of s, or of its first opening bracket if it has no brace.
+func openingIndex(s string) int {
+	if i := strings.IndexByte(s, '{'); i >= 0 {
+		return i
+	}
+
+	return strings.IndexByte(s, '[')
+}
+
+// balancedJSON returns the first span of s that starts with open, { or [, ends with the matching closing character
+// and is valid JSON.
+func balancedJSON(s string, open byte) (string, bool) {
+	for start := 0; start < len(s); start++ {
+		if s[start] != open {
+			continue
+		}
+
+		end := matchingClose(s, start)
+		if end < 0 {
+			continue
+		}
+		if v := s[start : end+1]; json.Valid([]byte(v)) {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+// matchingClose returns the index of the character closing the object or array starting at s[start], or -1 if it is
+// never closed. Braces and brackets inside strings are ignored.
+func matchingClose(s string, start int) int {
+	var stack []byte
+	inString, escaped := false, false
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inString && escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case inString && c == '"':
+			inString = false
+		case inString:
+		case c == '"':
+			inString = true
+		case c == '{':
+			stack = append(stack, '}')
+		case c == '[':
+			stack = append(stack, ']')
+		case c == '}' || c == ']':
+			if stack[len(stack)-1] != c {
+				return -1
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,101 @@
+package typechat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExtractJSON(t *testing.T) {
+	t.Run("it finds the JSON value in the response", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			response string
+			expected string
+		}{
+			{"plain", `{"a": 1}`, `{"a": 1}`},
+			{"surrounding whitespace", "\n  {\"a\": 1}\n", `{"a": 1}`},
+			{"json fence", "```json\n{\"a\": 1}\n```", `{"a": 1}`},
+			{"bare fence", "Sure!\n```\n[1, 2]\n```\nLet me know.", `[1, 2]`},
+			{"preamble", `Here is the JSON: {"a": "b"}`, `{"a": "b"}`},
+			{"trailing prose", `{"a": {"b": [1]}} I hope this helps!`, `{"a": {"b": [1]}}`},
+			{"braces in strings", `Result: {"a": "}{]["} done`, `{"a": "}{]["}`},
+			{"escaped quotes", `{"a": "say \"}\""}`, `{"a": "say \"}\""}`},
+			{"prose with brackets", `Here [as requested] is {"a": 1}`, `{"a": 1}`},
+			{"array before an object", `Sure, see [1] for details: {"A": 1}`, `{"A": 1}`},
+			{"fenced array before an object", "```\n[1]\n```\n{\"a\": 1}", `{"a": 1}`},
+			{"unclosed bracket in prose", `Oops :-[ anyway {"a": 1}`, `{"a": 1}`},
+			{"fence without JSON", "```go\nx := 1\n```\n{\"a\": 1}", `{"a": 1}`},
+			{"invalid JSON", `Here: {a: 1} bye`, `{a: 1} bye`},
+			{"truncated", `Here: {"a": [1, 2`, `{"a": [1, 2`},
+			{"invalid JSON in fence", "```json\n{a: 1,}\n```\nDone.", `{a: 1,}`},
+		}
+
+		for _, test := range tests {
+			got, err := ExtractJSON(test.response)
+			if err != nil {
+				t.Errorf("%s: expected err to be nil, got %s", test.name, err)
+				continue
+			}
+			if got != test.expected {
+				t.Errorf("%s: expected %s, got %s", test.name, test.expected, got)
+			}
+		}
+	})
+
+	t.Run("it reports responses without JSON", func(t *testing.T) {
+		_, err := ExtractJSON("I'm sorry, I can't help with that.")
+		if !errors.Is(err, ErrNoJSON) {
+			t.Errorf("expected err to be ErrNoJSON, got %v", err)
+		}
+	})
+
+	t.Run("it extracts the JSON of fenced responses before decoding", func(t *testing.T) {
+		m := mockModelClient{response: "Here is the JSON:\n```json\n{\"start\": 1, \"end\": 2}\n```"}
+
+		result, err := NewPrompt[testBooking](m, "").Execute(context.Background())
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if result.End != 2 {
+			t.Errorf("expected end to be 2, got %d", result.End)
+		}
+	})
+
+	t.Run("it uses the extractor of the prompt", func(t *testing.T) {
+		m := mockModelClient{response: `<answer>{"start": 1, "end": 2}</answer>`}
+		var called bool
+		extractor := ExtractorFunc(func(response string) (string, error) {
+			called = true
+			response = strings.TrimPrefix(response, "<answer>")
+			return strings.TrimSuffix(response, "</answer>"), nil
+		})
+
+		_, err := NewPrompt[testBooking](m, "", PromptExtractor[testBooking](extractor)).Execute(context.Background())
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if !called {
+			t.Error("expected extractor to be called")
+		}
+	})
+
+	t.Run("it sends extraction errors to the model for repair", func(t *testing.T) {
+		var prompts [][]Message
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			prompts = append(prompts, prompt)
+			return "I don't know.", nil
+		})
+
+		p := NewPrompt[testBooking](m, "", PromptRetries[testBooking](1))
+		if _, err := p.Execute(context.Background()); err == nil {
+			t.Fatal("expected err to be non-nil")
+		}
+
+		repair := prompts[1][len(prompts[1])-1].Content
+		if !strings.Contains(repair, ErrNoJSON.Error()) {
+			t.Errorf("expected repair prompt to contain %q, got %s", ErrNoJSON, repair)
+		}
+	})
+}
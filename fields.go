@@ -0,0 +1,187 @@
+package typechat
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// structField is a field of a struct as seen by encoding/json. Fields of embedded structs are promoted into the
+// outer struct, so index may point more than one level deep.
+type structField struct {
+	name      string // JSON property name
+	tagged    bool   // name was given by a json tag
+	omitEmpty bool   // the json tag has the omitempty option
+	quoted    bool   // the json tag has the string option and the value is encoded inside a JSON string
+	index     []int
+	field     reflect.StructField
+}
+
+// structFields returns the fields encoding/json reads for t, in declaration order. It follows the encoding/json rules
+// for embedded structs: their fields are promoted, shallower fields shadow deeper ones, tagged fields win over
+// untagged fields at the same depth and any remaining conflicts cause the field to be dropped.
+func structFields(t reflect.Type) []structField {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var current []embedded
+	next := []embedded{{typ: t}}
+
+	var count, nextCount map[reflect.Type]int
+	visited := map[reflect.Type]bool{}
+
+	var fields []structField
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+
+			for i := 0; i < e.typ.NumField(); i++ {
+				sf := e.typ.Field(i)
+				if sf.Anonymous {
+					ft := sf.Type
+					if ft.Kind() == reflect.Pointer {
+						// json.Unmarshal cannot allocate embedded pointers to unexported structs.
+						if !sf.IsExported() {
+							continue
+						}
+						ft = ft.Elem()
+					}
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+
+				index := make([]int, len(e.index)+1)
+				copy(index, e.index)
+				index[len(e.index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					f := structField{
+						name:      name,
+						tagged:    name != "",
+						omitEmpty: tagOption(opts, "omitempty"),
+						index:     index,
+						field:     sf,
+					}
+					if tagOption(opts, "string") {
+						switch ft.Kind() {
+						case reflect.Bool,
+							reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+							reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+							reflect.Float32, reflect.Float64,
+							reflect.String:
+							f.quoted = true
+						}
+					}
+					if f.name == "" {
+						f.name = sf.Name
+					}
+					fields = append(fields, f)
+
+					// The same struct embedded twice at one level makes its fields ambiguous, record the field
+					// twice so it is dropped when resolving conflicts below.
+					if count[e.typ] > 1 {
+						fields = append(fields, f)
+					}
+					continue
+				}
+
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, embedded{typ: ft, index: index})
+				}
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		x, y := fields[i], fields[j]
+		if x.name != y.name {
+			return x.name < y.name
+		}
+		if len(x.index) != len(y.index) {
+			return len(x.index) < len(y.index)
+		}
+		if x.tagged != y.tagged {
+			return x.tagged
+		}
+		return indexLess(x.index, y.index)
+	})
+
+	resolved := fields[:0]
+	for i, advance := 0, 0; i < len(fields); i += advance {
+		name := fields[i].name
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != name {
+				break
+			}
+		}
+
+		if f, ok := dominantField(fields[i : i+advance]); ok {
+			resolved = append(resolved, f)
+		}
+	}
+
+	sort.Slice(resolved, func(i, j int) bool {
+		return indexLess(resolved[i].index, resolved[j].index)
+	})
+
+	return resolved
+}
+
+// dominantField picks the field that wins among fields sharing a JSON name. The fields must be sorted by depth and
+// then by whether they are tagged, it reports false when no single field wins.
+func dominantField(fields []structField) (structField, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tagged == fields[1].tagged {
+		return structField{}, false
+	}
+
+	return fields[0], true
+}
+
+// tagOption reports whether the comma separated json tag options contain opt.
+func tagOption(opts, opt string) bool {
+	for opts != "" {
+		var o string
+		o, opts, _ = strings.Cut(opts, ",")
+		if o == opt {
+			return true
+		}
+	}
+
+	return false
+}
+
+func indexLess(x, y []int) bool {
+	for i := range x {
+		if i >= len(y) {
+			return false
+		}
+		if x[i] != y[i] {
+			return x[i] < y[i]
+		}
+	}
+
+	return len(x) < len(y)
+}
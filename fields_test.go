@@ -0,0 +1,90 @@
+package typechat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func fieldNames(fields []structField) []string {
+	var names []string
+	for _, f := range fields {
+		names = append(names, f.name)
+	}
+
+	return names
+}
+
+func TestStructFields(t *testing.T) {
+	t.Run("it prefers tagged fields at the same depth", func(t *testing.T) {
+		type A struct {
+			Name string `json:"name"`
+		}
+		type B struct {
+			Name string
+		}
+		type Foo struct {
+			A
+			B
+		}
+
+		fields := structFields(reflect.TypeOf(Foo{}))
+		if got := fieldNames(fields); !reflect.DeepEqual(got, []string{"name", "Name"}) {
+			t.Errorf("expected [name Name], got %v", got)
+		}
+		if !reflect.DeepEqual(fields[0].index, []int{0, 0}) {
+			t.Errorf("expected index [0 0], got %v", fields[0].index)
+		}
+	})
+
+	t.Run("it drops ambiguous fields", func(t *testing.T) {
+		type A struct {
+			Name string
+		}
+		type B struct {
+			Name string
+		}
+		type Foo struct {
+			A
+			B
+			Other string
+		}
+
+		fields := structFields(reflect.TypeOf(Foo{}))
+		if got := fieldNames(fields); !reflect.DeepEqual(got, []string{"Other"}) {
+			t.Errorf("expected [Other], got %v", got)
+		}
+	})
+
+	t.Run("it lets shallower fields shadow embedded fields", func(t *testing.T) {
+		type Inner struct {
+			Name string
+		}
+		type Outer struct {
+			Inner
+		}
+		type Foo struct {
+			Outer
+			Name int
+		}
+
+		fields := structFields(reflect.TypeOf(Foo{}))
+		if len(fields) != 1 || fields[0].field.Type.Kind() != reflect.Int {
+			t.Errorf("expected a single int field, got %v", fields)
+		}
+	})
+
+	t.Run("it skips embedded pointers to unexported structs", func(t *testing.T) {
+		type inner struct {
+			Name string
+		}
+		type Foo struct {
+			*inner
+			Other string
+		}
+
+		fields := structFields(reflect.TypeOf(Foo{}))
+		if got := fieldNames(fields); !reflect.DeepEqual(got, []string{"Other"}) {
+			t.Errorf("expected [Other], got %v", got)
+		}
+	})
+}
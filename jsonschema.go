@@ -0,0 +1,207 @@
+package typechat
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema returns the JSON Schema (draft 2020-12) of the struct type T. It is generated from the same
+// declarations used to build prompts, so it can be passed to providers supporting structured outputs or to other
+// validators. The result can be encoded with encoding/json.
+//
+// The root of the schema describes T itself, every other named type is defined under $defs. Constraints on fields
+// using the string option of their json tag are not part of the schema.
+func JSONSchema[T any]() (map[string]any, error) {
+	var v T
+	s, root, err := structSchema(reflect.TypeOf(v))
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonSchemaOf(s, root), nil
+}
+
+func jsonSchemaOf(s *schema, root *typeDecl) map[string]any {
+	r := jsonSchemaRenderer{root: root}
+
+	out := map[string]any{"$schema": jsonSchemaDialect}
+	for k, v := range r.decl(root) {
+		out[k] = v
+	}
+
+	defs := map[string]any{}
+	for _, d := range s.decls {
+		if d != root {
+			defs[d.name] = r.decl(d)
+		}
+	}
+	if len(defs) > 0 {
+		out["$defs"] = defs
+	}
+
+	return out
+}
+
+type jsonSchemaRenderer struct {
+	root *typeDecl
+}
+
+func (r jsonSchemaRenderer) decl(d *typeDecl) map[string]any {
+	var out map[string]any
+	switch d.kind {
+	case declStruct:
+		out = r.object(d)
+	case declScalar:
+		out = jsonSchemaScalar(d.scalar)
+		if d.format != "" {
+			out["format"] = d.format
+		}
+		if d.enum != nil {
+			values := make([]any, len(d.enum))
+			for i, l := range d.enum {
+				values[i] = json.RawMessage(l.value)
+			}
+			out["enum"] = values
+		}
+	case declAlias:
+		out = r.expr(d.alias)
+	}
+
+	if d.description != "" {
+		out["description"] = d.description
+	}
+
+	return out
+}
+
+func (r jsonSchemaRenderer) object(d *typeDecl) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for _, f := range d.fields {
+		var p map[string]any
+		switch {
+		case f.quoted:
+			// Constraints apply to the value encoded in the string, which JSON Schema cannot express, so they are
+			// only checked when responses are validated.
+			p = nullableSchema(map[string]any{"type": "string"}, f.typ.nullable)
+		case f.constraints != nil:
+			p = r.expr(f.typ)
+			// Keywords next to a $ref or anyOf would only apply to one branch or be ignored by older validators,
+			// so the constraints are combined with the type instead.
+			if _, ok := p["type"]; !ok {
+				p = map[string]any{"allOf": []any{p}}
+			}
+			f.constraints.jsonSchema(f.typ, p)
+		default:
+			p = r.expr(f.typ)
+		}
+
+		if f.description != "" {
+			p["description"] = f.description
+		}
+		properties[f.name] = p
+
+		if !f.optional {
+			required = append(required, f.name)
+		}
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+func (r jsonSchemaRenderer) expr(e *typeExpr) map[string]any {
+	var out map[string]any
+	switch e.kind {
+	case exprScalar:
+		out = jsonSchemaScalar(e.scalar)
+		if e.format == "base64" {
+			out["contentEncoding"] = "base64"
+		}
+	case exprAny:
+		// any JSON value, null included
+		return map[string]any{}
+	case exprSlice:
+		out = map[string]any{
+			"type":  "array",
+			"items": r.expr(e.elem),
+		}
+	case exprMap:
+		out = map[string]any{
+			"type":                 "object",
+			"additionalProperties": r.expr(e.elem),
+		}
+		if keys := r.propertyNames(e.key); keys != nil {
+			out["propertyNames"] = keys
+		}
+	case exprNamed:
+		switch {
+		case e.decl == r.root:
+			out = map[string]any{"$ref": "#"}
+		case e.decl.name == "":
+			out = r.decl(e.decl)
+		default:
+			out = map[string]any{"$ref": "#/$defs/" + e.decl.name}
+		}
+	}
+
+	return nullableSchema(out, e.nullable)
+}
+
+// propertyNames restricts the keys of a map, which are always strings in JSON, to the values its key type accepts.
+func (r jsonSchemaRenderer) propertyNames(key *typeExpr) map[string]any {
+	switch {
+	case key.kind == exprNamed:
+		return r.expr(key)
+	case key.kind != exprScalar:
+		return nil
+	}
+
+	switch key.scalar {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"pattern": "^-?[0-9]+$"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]any{"pattern": "^[0-9]+$"}
+	}
+
+	return nil
+}
+
+// nullableSchema allows null in addition to the values accepted by schema.
+func nullableSchema(schema map[string]any, nullable bool) map[string]any {
+	if !nullable {
+		return schema
+	}
+
+	if t, ok := schema["type"].(string); ok {
+		schema["type"] = []string{t, "null"}
+		return schema
+	}
+
+	return map[string]any{
+		"anyOf": []any{schema, map[string]any{"type": "null"}},
+	}
+}
+
+func jsonSchemaScalar(k reflect.Kind) map[string]any {
+	switch k {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	default:
+		return map[string]any{}
+	}
+}
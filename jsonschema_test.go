@@ -0,0 +1,109 @@
+package typechat
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func assertJSONSchema(t *testing.T, schema map[string]any, expected string) {
+	t.Helper()
+
+	got, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		t.Fatalf("expected err to be nil, got %s", err)
+	}
+
+	var want bytes.Buffer
+	if err := json.Indent(&want, []byte(expected), "", "  "); err != nil {
+		t.Fatalf("invalid expected schema: %s", err)
+	}
+
+	if string(got) != want.String() {
+		t.Errorf("expected schema to be:\n%s\ngot:\n%s", want.String(), got)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	RegisterEnum[testPriority]("low", "medium", "high")
+
+	t.Run("it generates a JSON schema", func(t *testing.T) {
+		type Task struct {
+			Title     string                `json:"title" description:"a short summary"`
+			Priority  testPriority          `json:"priority,omitempty"`
+			Due       *time.Time            `json:"due"`
+			Estimate  uint                  `json:"estimate"`
+			Tags      []string              `json:"tags"`
+			Owners    map[testUserID]string `json:"owners"`
+			Counts    map[int]float64       `json:"counts"`
+			Attrs     map[string]any        `json:"-"`
+			Quantity  int                   `json:"quantity,string"`
+			Blob      []byte                `json:"blob"`
+			Subtasks  []Task                `json:"subtasks"`
+			Reference *testNode             `json:"reference"`
+		}
+
+		schema, err := JSONSchema[Task]()
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `{
+  "$defs": {
+    "Time": {
+      "description": "Time is a timestamp in RFC 3339 format, such as \"2006-01-02T15:04:05Z\".",
+      "format": "date-time",
+      "type": "string"
+    },
+    "testNode": {
+      "additionalProperties": false,
+      "properties": {
+        "Children": {"items": {"$ref": "#/$defs/testNode"}, "type": "array"},
+        "Name": {"type": "string"},
+        "Parent": {"anyOf": [{"$ref": "#/$defs/testNode"}, {"type": "null"}]}
+      },
+      "required": ["Name", "Children"],
+      "type": "object"
+    },
+    "testPriority": {"enum": ["low", "medium", "high"], "type": "string"},
+    "testUserID": {
+      "description": "testUserID is the unique identifier of a user account.",
+      "type": "string"
+    }
+  },
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "additionalProperties": false,
+  "properties": {
+    "blob": {"contentEncoding": "base64", "type": "string"},
+    "counts": {
+      "additionalProperties": {"type": "number"},
+      "propertyNames": {"pattern": "^-?[0-9]+$"},
+      "type": "object"
+    },
+    "due": {"anyOf": [{"$ref": "#/$defs/Time"}, {"type": "null"}]},
+    "estimate": {"minimum": 0, "type": "integer"},
+    "owners": {
+      "additionalProperties": {"type": "string"},
+      "propertyNames": {"$ref": "#/$defs/testUserID"},
+      "type": "object"
+    },
+    "priority": {"$ref": "#/$defs/testPriority"},
+    "quantity": {"type": "string"},
+    "reference": {"anyOf": [{"$ref": "#/$defs/testNode"}, {"type": "null"}]},
+    "subtasks": {"items": {"$ref": "#"}, "type": "array"},
+    "tags": {"items": {"type": "string"}, "type": "array"},
+    "title": {"description": "a short summary", "type": "string"}
+  },
+  "required": ["title", "estimate", "tags", "owners", "counts", "quantity", "blob", "subtasks"],
+  "type": "object"
+}`
+		assertJSONSchema(t, schema, expected)
+	})
+
+	t.Run("it requires a struct", func(t *testing.T) {
+		if _, err := JSONSchema[[]string](); err == nil {
+			t.Fatal("expected err to be non-nil")
+		}
+	})
+}
@@ -0,0 +1,300 @@
+package typechat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSONFix is a kind of mistake fixed in a response before decoding it.
+type JSONFix string
+
+const (
+	JSONFixComments       JSONFix = "removed comments"
+	JSONFixTrailingCommas JSONFix = "removed trailing commas"
+	JSONFixSingleQuotes   JSONFix = "converted single-quoted strings"
+	JSONFixUnquotedKeys   JSONFix = "quoted unquoted keys"
+	JSONFixUnclosed       JSONFix = "closed truncated strings, objects and arrays"
+	JSONFixTrailingText   JSONFix = "removed text after the JSON value"
+	JSONFixControlChars   JSONFix = "escaped control characters in strings"
+)
+
+// fixJSON rewrites the almost-JSON models commonly produce as strict JSON. It accepts comments, trailing commas,
+// single-quoted strings, unquoted keys, raw newlines and other control characters in strings and values truncated
+// before their closing quotes, braces and brackets, and returns the fixes it applied in the order they were first
+// needed. Anything else is an error, since guessing further would risk changing what the model meant.
+func fixJSON(s string) (string, []JSONFix, error) {
+	p := &lenientParser{in: s}
+	p.space()
+	if p.eof() {
+		return "", nil, fmt.Errorf("unexpected end of JSON input")
+	}
+	if err := p.value(); err != nil {
+		return "", nil, err
+	}
+
+	p.space()
+	if !p.eof() {
+		p.fix(JSONFixTrailingText)
+	}
+
+	return string(p.out), p.fixes, nil
+}
+
+// errTruncated is returned for a number or literal cut off by the end of the input.
+var errTruncated = errors.New("truncated value")
+
+type lenientParser struct {
+	in    string
+	pos   int
+	out   []byte
+	fixes []JSONFix
+}
+
+func (p *lenientParser) eof() bool {
+	return p.pos >= len(p.in)
+}
+
+func (p *lenientParser) peek() byte {
+	return p.in[p.pos]
+}
+
+func (p *lenientParser) fix(f JSONFix) {
+	for _, applied := range p.fixes {
+		if applied == f {
+			return
+		}
+	}
+	p.fixes = append(p.fixes, f)
+}
+
+func (p *lenientParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// space skips whitespace and comments. An unterminated block comment runs to the end of the input.
+func (p *lenientParser) space() {
+	for !p.eof() {
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(p.peek())):
+			p.pos++
+		case strings.HasPrefix(p.in[p.pos:], "//"):
+			p.fix(JSONFixComments)
+			end := strings.IndexByte(p.in[p.pos:], '\n')
+			if end < 0 {
+				p.pos = len(p.in)
+				return
+			}
+			p.pos += end + 1
+		case strings.HasPrefix(p.in[p.pos:], "/*"):
+			p.fix(JSONFixComments)
+			end := strings.Index(p.in[p.pos+2:], "*/")
+			if end < 0 {
+				p.pos = len(p.in)
+				return
+			}
+			p.pos += end + 4
+		default:
+			return
+		}
+	}
+}
+
+func (p *lenientParser) value() error {
+	switch c := p.peek(); {
+	case c == '{':
+		return p.container('}')
+	case c == '[':
+		return p.container(']')
+	case c == '"' || c == '\'':
+		p.string()
+		return nil
+	default:
+		return p.literal()
+	}
+}
+
+// container copies an object or an array, closing it if the input ends first. A member cut off before its value is
+// left out.
+func (p *lenientParser) container(end byte) error {
+	object := end == '}'
+	p.out = append(p.out, p.peek())
+	p.pos++
+
+	for first := true; ; first = false {
+		p.space()
+		if p.eof() {
+			p.fix(JSONFixUnclosed)
+			p.out = append(p.out, end)
+			return nil
+		}
+		if p.peek() == end {
+			p.pos++
+			p.out = append(p.out, end)
+			return nil
+		}
+
+		mark := len(p.out)
+		if !first {
+			p.out = append(p.out, ',')
+		}
+
+		if object {
+			if err := p.key(); err != nil {
+				return err
+			}
+			p.space()
+			if p.eof() {
+				p.out = p.out[:mark]
+				continue
+			}
+			if p.peek() != ':' {
+				return p.errorf("expected a colon after the object key")
+			}
+			p.pos++
+			p.out = append(p.out, ':')
+			p.space()
+			if p.eof() {
+				p.out = p.out[:mark]
+				continue
+			}
+		}
+
+		if err := p.value(); errors.Is(err, errTruncated) {
+			p.out = p.out[:mark]
+			p.pos = len(p.in)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		p.space()
+		if p.eof() {
+			continue
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+			p.space()
+			if !p.eof() && p.peek() == end {
+				p.fix(JSONFixTrailingCommas)
+			}
+		case end:
+		default:
+			return p.errorf("expected a comma or %q", end)
+		}
+	}
+}
+
+func (p *lenientParser) key() error {
+	if c := p.peek(); c == '"' || c == '\'' {
+		p.string()
+		return nil
+	}
+
+	start := p.pos
+	for !p.eof() && identifierByte(p.peek()) {
+		p.pos++
+	}
+	if p.pos == start {
+		return p.errorf("expected an object key")
+	}
+
+	p.fix(JSONFixUnquotedKeys)
+	p.out = append(p.out, fmt.Sprintf("%q", p.in[start:p.pos])...)
+
+	return nil
+}
+
+// string copies a double or single-quoted string, closing it if the input ends first.
+func (p *lenientParser) string() {
+	quote := p.peek()
+	if quote == '\'' {
+		p.fix(JSONFixSingleQuotes)
+	}
+	p.pos++
+	p.out = append(p.out, '"')
+
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case c == quote:
+			p.pos++
+			p.out = append(p.out, '"')
+			return
+		case c == '\\' && p.pos+1 < len(p.in):
+			next := p.in[p.pos+1]
+			p.pos += 2
+			if next == '\'' {
+				// \' is not a JSON escape
+				p.out = append(p.out, '\'')
+			} else {
+				p.out = append(p.out, c, next)
+			}
+		case c == '\\':
+			// a lone backslash at the end of truncated input
+			p.pos++
+		case c == '"':
+			p.pos++
+			p.out = append(p.out, '\\', '"')
+		case c < 0x20:
+			p.fix(JSONFixControlChars)
+			p.pos++
+			p.out = append(p.out, controlEscape(c)...)
+		default:
+			_, size := utf8.DecodeRuneInString(p.in[p.pos:])
+			p.out = append(p.out, p.in[p.pos:p.pos+size]...)
+			p.pos += size
+		}
+	}
+
+	p.fix(JSONFixUnclosed)
+	p.out = append(p.out, '"')
+}
+
+// controlEscape returns the JSON escape of a control character, which strings cannot contain as is.
+func controlEscape(c byte) string {
+	switch c {
+	case '\n':
+		return `\n`
+	case '\r':
+		return `\r`
+	case '\t':
+		return `\t`
+	case '\b':
+		return `\b`
+	case '\f':
+		return `\f`
+	}
+
+	return fmt.Sprintf(`\u%04x`, c)
+}
+
+// literal copies a number, true, false or null.
+func (p *lenientParser) literal() error {
+	start := p.pos
+	for !p.eof() && (identifierByte(p.peek()) || strings.IndexByte("+-.", p.peek()) >= 0) {
+		p.pos++
+	}
+
+	lit := p.in[start:p.pos]
+	if lit == "" {
+		return p.errorf("unexpected %q", p.peek())
+	}
+	if !json.Valid([]byte(lit)) {
+		if p.eof() {
+			return errTruncated
+		}
+		p.pos = start
+		return p.errorf("unexpected %q", lit)
+	}
+	p.out = append(p.out, lit...)
+
+	return nil
+}
+
+func identifierByte(c byte) bool {
+	return c == '_' || c == '$' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
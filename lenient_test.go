@@ -0,0 +1,121 @@
+package typechat
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestFixJSON(t *testing.T) {
+	t.Run("it fixes common mistakes", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			input    string
+			expected string
+			fixes    []JSONFix
+		}{
+			{"valid", `{"a": [1, true, null]}`, `{"a":[1,true,null]}`, nil},
+			{"trailing commas", `{"a": [1, 2,], "b": 3,}`, `{"a":[1,2],"b":3}`, []JSONFix{JSONFixTrailingCommas}},
+			{"single quotes", `{'a': 'it\'s "quoted"'}`, `{"a":"it's \"quoted\""}`, []JSONFix{JSONFixSingleQuotes}},
+			{"unquoted keys", `{a: 1, $b_2: 2}`, `{"a":1,"$b_2":2}`, []JSONFix{JSONFixUnquotedKeys}},
+			{
+				"comments",
+				"{\n  // the name\n  \"a\": 1, /* the age */ \"b\": 2\n}",
+				`{"a":1,"b":2}`,
+				[]JSONFix{JSONFixComments},
+			},
+			{"truncated string", `{"a": ["x", "y`, `{"a":["x","y"]}`, []JSONFix{JSONFixUnclosed}},
+			{"truncated after comma", `{"a": 1,`, `{"a":1}`, []JSONFix{JSONFixUnclosed}},
+			{"truncated key", `{"a": 1, "b`, `{"a":1}`, []JSONFix{JSONFixUnclosed}},
+			{"truncated before value", `{"a": 1, "b": `, `{"a":1}`, []JSONFix{JSONFixUnclosed}},
+			{"truncated number", `{"a": 1, "b": 2.`, `{"a":1}`, []JSONFix{JSONFixUnclosed}},
+			{"trailing text", `{"a": 1} and that's it`, `{"a":1}`, []JSONFix{JSONFixTrailingText}},
+			{
+				"control characters",
+				"{\"a\": \"line\nbreak\tand\x01\"}",
+				`{"a":"line\nbreak\tand\u0001"}`,
+				[]JSONFix{JSONFixControlChars},
+			},
+			{
+				"control characters in single quotes",
+				"{'a': 'line\nbreak'}",
+				`{"a":"line\nbreak"}`,
+				[]JSONFix{JSONFixSingleQuotes, JSONFixControlChars},
+			},
+			{
+				"several fixes",
+				`{a: 'x', b: [1,], // done`,
+				`{"a":"x","b":[1]}`,
+				[]JSONFix{JSONFixUnquotedKeys, JSONFixSingleQuotes, JSONFixTrailingCommas, JSONFixComments, JSONFixUnclosed},
+			},
+		}
+
+		for _, test := range tests {
+			got, fixes, err := fixJSON(test.input)
+			if err != nil {
+				t.Errorf("%s: expected err to be nil, got %s", test.name, err)
+				continue
+			}
+			if got != test.expected {
+				t.Errorf("%s: expected %s, got %s", test.name, test.expected, got)
+			}
+			if !reflect.DeepEqual(fixes, test.fixes) {
+				t.Errorf("%s: expected fixes %v, got %v", test.name, test.fixes, fixes)
+			}
+		}
+	})
+
+	t.Run("it rejects what it cannot fix", func(t *testing.T) {
+		tests := []struct {
+			input    string
+			expected string
+		}{
+			{`{"a" 1}`, "offset 5: expected a colon after the object key"},
+			{`{"a": 1 "b": 2}`, `offset 8: expected a comma or '}'`},
+			{`{"a": yes}`, `offset 6: unexpected "yes"`},
+			{`{"a": @}`, `offset 6: unexpected '@'`},
+			{``, "unexpected end of JSON input"},
+		}
+
+		for _, test := range tests {
+			_, _, err := fixJSON(test.input)
+			if err == nil {
+				t.Errorf("%s: expected err to be %q, got nil", test.input, test.expected)
+				continue
+			}
+			if err.Error() != test.expected {
+				t.Errorf("%s: expected err to be %q, got %q", test.input, test.expected, err)
+			}
+		}
+	})
+
+	t.Run("it fixes responses before asking the model", func(t *testing.T) {
+		m := mockModelClient{response: "```json\n{start: 1, 'end': 2,}\n```"}
+		var reported []JSONFix
+
+		p := NewPrompt[testBooking](m, "", PromptOnJSONFix[testBooking](func(fixes []JSONFix) {
+			reported = fixes
+		}))
+		result, err := p.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if result.Start != 1 || result.End != 2 {
+			t.Errorf("expected booking from 1 to 2, got %+v", result)
+		}
+
+		expected := []JSONFix{JSONFixUnquotedKeys, JSONFixSingleQuotes, JSONFixTrailingCommas}
+		if !reflect.DeepEqual(reported, expected) {
+			t.Errorf("expected fixes %v, got %v", expected, reported)
+		}
+	})
+
+	t.Run("it can be disabled", func(t *testing.T) {
+		m := mockModelClient{response: `{"start": 1, "end": 2,}`}
+
+		p := NewPrompt[testBooking](m, "", PromptLenientJSON[testBooking](false))
+		if _, err := p.Execute(context.Background()); err == nil {
+			t.Fatal("expected err to be non-nil")
+		}
+	})
+}
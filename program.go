@@ -1,7 +1,9 @@
 package typechat
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
 	"strings"
 )
@@ -10,14 +12,83 @@ type Program struct {
 	Steps []FunctionCall
 }
 
+// FunctionCall is a step of a program. Args holds the decoded JSON arguments, where references to the results of
+// earlier steps are decoded as Ref values, including inside arrays and objects.
 type FunctionCall struct {
 	Name string
-	Args []interface{}
+	// Args may be left out for functions without parameters, the number of arguments is checked against the API.
+	Args []interface{} `json:",omitempty"`
+}
+
+func (c *FunctionCall) UnmarshalJSON(data []byte) error {
+	// the conversion drops the method so decoding the fields doesn't recurse
+	type functionCall FunctionCall
+	var call functionCall
+	if err := json.Unmarshal(data, &call); err != nil {
+		return err
+	}
+
+	for i, arg := range call.Args {
+		v, err := decodeRefs(arg)
+		if err != nil {
+			return fmt.Errorf("argument %d of %s: %w", i, call.Name, err)
+		}
+		call.Args[i] = v
+	}
+	*c = FunctionCall(call)
+
+	return nil
+}
+
+// Ref is an argument referring to the result of an earlier step of a program, written {"@ref": n} in JSON where n is
+// the index of the step, starting at 0.
+type Ref struct {
+	Step int
+}
+
+func (r Ref) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]int{refKey: r.Step})
+}
+
+const refKey = "@ref"
+
+// decodeRefs replaces the {"@ref": n} objects of a decoded JSON value with Ref values.
+func decodeRefs(v any) (any, error) {
+	switch v := v.(type) {
+	case []any:
+		for i, item := range v {
+			item, err := decodeRefs(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = item
+		}
+	case map[string]any:
+		if step, ok := v[refKey]; ok && len(v) == 1 {
+			n, ok := step.(float64)
+			if !ok || n < 0 || n != math.Trunc(n) {
+				return nil, fmt.Errorf("invalid reference %s, expected the index of a step", jsonText(step))
+			}
+			return Ref{Step: int(n)}, nil
+		}
+		for key, value := range v {
+			value, err := decodeRefs(value)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = value
+		}
+	}
+
+	return v, nil
 }
 
 const (
 	programSchemaInstructions = `You are a service that translates user requests into programs represented as JSON 
-using the following Go definitions:`
+using the following %s definitions:`
+
+	programReferenceInstructions = `The result of a function call can be passed to a later call, as an argument or as part 
+of one, with a reference of the form {"@ref": n} where n is the index of the call, starting at 0.`
 
 	programPromptInstructions = `The following is the user request translated into a JSON object with 2 spaces of 
 indentation and no properties with the value undefined:`
@@ -25,11 +96,16 @@ indentation and no properties with the value undefined:`
 
 type program[T any] struct {
 	input    string
+	renderer renderer
 	messages []Message
+
+	schemaDecls *schema
+	schemaRoot  *typeDecl
+	api         *apiSchema
 }
 
-func newProgram[T any](i string) *program[T] {
-	return &program[T]{input: i}
+func newProgram[T any](i string, r renderer) *program[T] {
+	return &program[T]{input: i, renderer: r}
 }
 
 func (b *program[T]) prompt() ([]Message, error) {
@@ -37,29 +113,73 @@ func (b *program[T]) prompt() ([]Message, error) {
 		return b.messages, nil
 	}
 
-	schema := new(T)
-	schemaElem := reflect.TypeOf(schema).Elem()
-	def, err := interfaceDef(schemaElem)
+	api, err := b.apiSchema()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get definition of schema: %w", err)
 	}
 
-	schemaPrompt, err := b.schema(def)
+	schemaPrompt, err := b.schema(api)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build schema: %w", err)
 	}
 
 	b.messages = append(b.messages, newSystemMessage(schemaPrompt))
+	// The instructions follow the request in the same message, since some providers only accept a leading system
+	// message.
 	b.messages = append(b.messages, newUserMessage(b.userMessage()))
-	b.messages = append(b.messages, newSystemMessage(b.instructions()))
 
 	return b.messages, nil
 }
 
+func (b *program[T]) responseSchema() (*schema, *typeDecl, error) {
+	if b.schemaRoot != nil {
+		return b.schemaDecls, b.schemaRoot, nil
+	}
+
+	s, decl, err := structSchema(reflect.TypeOf(Program{}))
+	if err != nil {
+		return nil, nil, err
+	}
+	b.schemaDecls, b.schemaRoot = s, decl
+
+	return s, decl, nil
+}
+
+// apiSchema returns the description of the API interface T, declaring the types of its parameters and results.
+func (b *program[T]) apiSchema() (*apiSchema, error) {
+	if b.api != nil {
+		return b.api, nil
+	}
+
+	s, _, err := b.responseSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	api, err := newAPISchema(reflect.TypeOf((*T)(nil)).Elem(), s)
+	if err != nil {
+		return nil, err
+	}
+	b.api = api
+
+	return api, nil
+}
+
+// validate checks that the steps of the decoded *Program output call the API correctly.
+func (b *program[T]) validate(output any) error {
+	api, err := b.apiSchema()
+	if err != nil {
+		return err
+	}
+
+	return api.validate(*output.(*Program))
+}
+
 func (b *program[T]) userMessage() string {
 	var sb strings.Builder
 	sb.WriteString(newline("The following is a user request:"))
 	sb.WriteString(newline(b.input))
+	sb.WriteString(b.instructions())
 
 	return sb.String()
 }
@@ -68,19 +188,25 @@ func (b *program[T]) instructions() string {
 	return programPromptInstructions
 }
 
-func (b *program[T]) schema(def string) (string, error) {
+func (b *program[T]) schema(api *apiSchema) (string, error) {
 	var sb strings.Builder
 	sb.WriteString(newline("A program consists of a sequence of function calls that are evaluated in order."))
-	sb.WriteString(newline(programSchemaInstructions))
+	sb.WriteString(newline(programReferenceInstructions))
+	sb.WriteString(newline(fmt.Sprintf(programSchemaInstructions, b.renderer.language())))
 
-	_, programDef, err := structDef(reflect.TypeOf(Program{}))
+	s, _, err := b.responseSchema()
 	if err != nil {
 		return "", err
 	}
-	sb.WriteString(programDef)
-
-	sb.WriteString(newline("The programs can call functions from the API defined in the following Go definitions:"))
-	sb.WriteString(def)
+	// the API declares its types in the schema of the response, after the types of the program
+	programDecls := s.decls[:len(s.decls)-len(api.decls)]
+	sb.WriteString(b.renderer.decls(&schema{decls: programDecls}))
+
+	sb.WriteString(newline(fmt.Sprintf("The programs can call functions from the API defined in the following %s definitions:",
+		b.renderer.language())))
+	// the types of the parameters and results are declared before the API that uses them
+	sb.WriteString(b.renderer.decls(&schema{decls: api.decls}))
+	sb.WriteString(b.renderer.api(api))
 
 	return sb.String(), nil
 }
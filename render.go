@@ -0,0 +1,218 @@
+package typechat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SchemaDialect is the language used to describe types to the model.
+type SchemaDialect struct {
+	name string
+}
+
+func (d SchemaDialect) String() string {
+	return d.name
+}
+
+var (
+	// DialectGo describes types with Go declarations. It is the default dialect.
+	DialectGo = SchemaDialect{name: "go"}
+	// DialectTypeScript describes types with TypeScript interfaces and type aliases, which TypeChat prompts were
+	// originally written for.
+	DialectTypeScript = SchemaDialect{name: "typescript"}
+)
+
+// renderer writes the declarations of a schema in a schema dialect.
+type renderer interface {
+	// language is the name of the dialect used in the prompt instructions.
+	language() string
+	// decls renders every declaration of the schema, in dependency order.
+	decls(s *schema) string
+	// api renders the declaration of the API interface used by programs.
+	api(a *apiSchema) string
+}
+
+func newRenderer(d SchemaDialect) (renderer, error) {
+	switch d {
+	case DialectGo:
+		return goRenderer{}, nil
+	case DialectTypeScript:
+		return tsRenderer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown schema dialect %s", d)
+	}
+}
+
+type goRenderer struct{}
+
+func (goRenderer) language() string {
+	return "Go"
+}
+
+// decls renders the declarations of s as Go source, in dependency order.
+func (goRenderer) decls(s *schema) string {
+	var sb strings.Builder
+	for _, d := range s.decls {
+		sb.WriteString(goDecl(d))
+	}
+
+	return sb.String()
+}
+
+// api renders the API interface as a Go interface, with the registered descriptions of its methods as comments.
+func (goRenderer) api(a *apiSchema) string {
+	var methods strings.Builder
+	for _, m := range a.methods {
+		doc := []string{m.description}
+		for _, p := range m.params {
+			if p.description != "" {
+				doc = append(doc, fmt.Sprintf("%s: %s", p.name, p.description))
+			}
+		}
+		for _, line := range strings.SplitAfter(docComment(strings.Join(doc, "\n")), "\n") {
+			if line != "" {
+				methods.WriteString("\t" + line)
+			}
+		}
+
+		var args []string
+		for _, p := range m.params {
+			arg := goExpr(p.expr, "\t")
+			if m.named {
+				arg = fmt.Sprintf("%s %s", p.name, arg)
+			}
+			args = append(args, arg)
+		}
+
+		var returns []string
+		for _, r := range m.results {
+			returns = append(returns, r.goName("\t"))
+		}
+		if m.error {
+			returns = append(returns, "error")
+		}
+
+		var result string
+		if len(returns) > 0 {
+			result = fmt.Sprintf(" (%s)", strings.Join(returns, ", "))
+		}
+
+		methods.WriteString(fmt.Sprintf("\t%s(%s)%s\n", m.name, strings.Join(args, ", "), result))
+	}
+
+	return fmt.Sprintf("type %s interface {\n%s}\n", a.name, methods.String())
+}
+
+func goDecl(d *typeDecl) string {
+	var sb strings.Builder
+	sb.WriteString(docComment(d.description))
+
+	switch d.kind {
+	case declStruct:
+		sb.WriteString(fmt.Sprintf("type %s %s\n", d.name, goStruct(d, "")))
+	case declScalar:
+		if d.enum != nil {
+			var values []string
+			for _, l := range d.enum {
+				values = append(values, l.String())
+			}
+			sb.WriteString(fmt.Sprintf("// %s must be one of: %s\n", d.name, strings.Join(values, ", ")))
+		}
+		sb.WriteString(fmt.Sprintf("type %s %s\n", d.name, d.scalar))
+	case declAlias:
+		sb.WriteString(fmt.Sprintf("type %s %s\n", d.name, goExpr(d.alias, "")))
+	}
+
+	return sb.String()
+}
+
+func goStruct(d *typeDecl, indent string) string {
+	var fields strings.Builder
+	for _, f := range d.fields {
+		var structTags []string
+		switch {
+		case f.name == "-":
+			// a bare "-" would tell the model the field is skipped
+			structTags = append(structTags, `json:"-,"`)
+		case f.name != f.goName:
+			structTags = append(structTags, fmt.Sprintf("json:\"%s\"", f.name))
+		}
+
+		if f.description != "" {
+			structTags = append(structTags, fmt.Sprintf("description:\"%s\"", f.description))
+		}
+
+		var structTag string
+		if len(structTags) > 0 {
+			structTag = fmt.Sprintf(" `%s`", strings.Join(structTags, " "))
+		}
+
+		// Pointer and omitempty fields may be omitted from the JSON object, pointers may also be null.
+		typName := goExpr(f.typ, indent+"\t")
+		var comments []string
+		if f.optional {
+			comments = append(comments, "optional")
+		}
+		if f.typ.format == "base64" {
+			comments = append(comments, "base64 encoded")
+		}
+		if f.quoted {
+			comments = append(comments, fmt.Sprintf("%s encoded as a JSON string", strings.TrimPrefix(typName, "*")))
+			typName = "string"
+			if f.typ.nullable {
+				typName = "*string"
+			}
+		}
+		if f.constraints != nil {
+			comments = append(comments, f.constraints.describe(f.typ, true)...)
+		}
+
+		var comment string
+		if len(comments) > 0 {
+			comment = fmt.Sprintf(" // %s", strings.Join(comments, ", "))
+		}
+
+		fields.WriteString(fmt.Sprintf("%s\t%s %s%s%s\n", indent, f.goName, typName, structTag, comment))
+	}
+
+	return fmt.Sprintf("struct {\n%s%s}", fields.String(), indent)
+}
+
+func goExpr(e *typeExpr, indent string) string {
+	var name string
+	switch e.kind {
+	case exprScalar:
+		name = e.scalar.String()
+	case exprAny:
+		name = "interface{}"
+	case exprSlice:
+		name = "[]" + goExpr(e.elem, indent)
+	case exprMap:
+		name = fmt.Sprintf("map[%s]%s", goExpr(e.key, indent), goExpr(e.elem, indent))
+	case exprNamed:
+		name = e.decl.name
+		if name == "" {
+			name = goStruct(e.decl, indent)
+		}
+	}
+
+	if e.nullable {
+		name = "*" + name
+	}
+
+	return name
+}
+
+// docComment formats a type description as a Go comment placed before its declaration.
+func docComment(description string) string {
+	if description == "" {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(description), "\n") {
+		sb.WriteString(newline(strings.TrimSpace("// " + line)))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,136 @@
+package typechat
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// RepairStrategy decides which previous responses are sent to the model when it is asked to fix a response.
+type RepairStrategy struct {
+	name string
+}
+
+func (s RepairStrategy) String() string {
+	return s.name
+}
+
+var (
+	// RepairLastOnly sends the original prompt followed by the last rejected response and why it was rejected. It is
+	// the default strategy.
+	RepairLastOnly = RepairStrategy{name: "last-only"}
+	// RepairFullHistory sends the original prompt followed by every rejected response and why it was rejected, so the
+	// model does not repeat earlier mistakes.
+	RepairFullHistory = RepairStrategy{name: "full-history"}
+	// RepairCompact sends only the schema, the last rejected response and why it was rejected, leaving out the user
+	// request to save tokens.
+	RepairCompact = RepairStrategy{name: "compact"}
+)
+
+// RepairData is passed to the template of repair messages.
+type RepairData struct {
+	// Object names what the model was asked for, "JSON object" or "JSON program object".
+	Object string
+	// Response is the rejected response. It is only part of the conversation sent to the model with RepairCompact if
+	// the template includes it.
+	Response string
+	// Reason is why the response was rejected, one problem per line.
+	Reason string
+}
+
+const (
+	defaultRepairTemplate = `The {{.Object}} is invalid for the following reason:
+{{.Reason}}
+The following is a revised {{.Object}}:
+`
+
+	compactRepairTemplate = `The following {{.Object}} is invalid:
+{{.Response}}
+for the following reason:
+{{.Reason}}
+The following is a revised {{.Object}}:
+`
+)
+
+// RepairRoler is implemented by model clients whose provider needs repair messages sent with a specific role, used
+// unless PromptRepairRole is set.
+type RepairRoler interface {
+	RepairRole() Role
+}
+
+// repairOptions configure how the model is asked to fix its responses.
+type repairOptions struct {
+	strategy RepairStrategy
+	role     Role
+	template string // empty for the default template of the strategy
+}
+
+// rejectedResponse is a response the model was asked to fix.
+type rejectedResponse struct {
+	response string
+	reason   error
+}
+
+func newRepairTemplate(opts repairOptions) (*template.Template, error) {
+	text := opts.template
+	switch {
+	case text != "":
+	case opts.strategy == RepairCompact:
+		text = compactRepairTemplate
+	default:
+		text = defaultRepairTemplate
+	}
+
+	tmpl, err := template.New("repair").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repair template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// repairMessages returns the conversation asking the model to fix the last of the rejected responses.
+func (b *builder[T]) repairMessages(prompt []Message, rejected []rejectedResponse) ([]Message, error) {
+	// prompt is cached by the prompt builder, so it is copied before appending to it
+	msgs := make([]Message, len(prompt), len(prompt)+2*len(rejected))
+	copy(msgs, prompt)
+
+	if b.repairOpts.strategy == RepairCompact {
+		// the schema is the first message of every prompt
+		msgs = msgs[:1]
+	}
+	if b.repairOpts.strategy == RepairLastOnly || b.repairOpts.strategy == RepairCompact {
+		rejected = rejected[len(rejected)-1:]
+	}
+
+	for _, r := range rejected {
+		content, err := b.repairMessage(r)
+		if err != nil {
+			return nil, err
+		}
+		if b.repairOpts.strategy != RepairCompact {
+			msgs = append(msgs, newAssistantMessage(r.response))
+		}
+		msgs = append(msgs, Message{Content: content, Role: b.repairOpts.role})
+	}
+
+	return msgs, nil
+}
+
+func (b *builder[T]) repairMessage(r rejectedResponse) (string, error) {
+	data := RepairData{
+		Object:   "JSON object",
+		Response: r.response,
+		Reason:   r.reason.Error(),
+	}
+	if b.pt == promptProgram {
+		data.Object = "JSON program object"
+	}
+
+	var sb strings.Builder
+	if err := b.repairTemplate.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to execute repair template: %w", err)
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,207 @@
+package typechat
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRepairStrategy(t *testing.T) {
+	type Result struct {
+		Sentiment string `json:"sentiment"`
+	}
+
+	// execute runs a prompt whose model answers with each response in turn and returns the prompts it was sent.
+	execute := func(t *testing.T, strategy RepairStrategy, responses ...string) [][]Message {
+		t.Helper()
+
+		var prompts [][]Message
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			prompts = append(prompts, prompt)
+			return responses[len(prompts)-1], nil
+		})
+
+		p := NewPrompt[Result](m, "It was great", PromptRetries[Result](len(responses)-1),
+			PromptRepairStrategy[Result](strategy))
+		if _, err := p.Execute(context.Background()); err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		return prompts
+	}
+
+	responses := []string{`{"sentiment": 1}`, `{"mood": "positive"}`, `{"sentiment": "positive"}`}
+
+	t.Run("it sends only the last response by default", func(t *testing.T) {
+		prompts := execute(t, RepairLastOnly, responses...)
+
+		last := prompts[2]
+		if len(last) != len(prompts[0])+2 {
+			t.Fatalf("expected %d messages, got %d", len(prompts[0])+2, len(last))
+		}
+		if last[len(last)-2].Role != RoleAssistant || last[len(last)-2].Content != responses[1] {
+			t.Errorf("expected the last response, got %+v", last[len(last)-2])
+		}
+
+		// earlier prompts are not changed by later repairs
+		if prompts[1][len(prompts[1])-2].Content != responses[0] {
+			t.Errorf("expected the first repair to keep the first response, got %+v", prompts[1][len(prompts[1])-2])
+		}
+	})
+
+	t.Run("it sends every previous response with the full history", func(t *testing.T) {
+		prompts := execute(t, RepairFullHistory, responses...)
+
+		last := prompts[2]
+		if len(last) != len(prompts[0])+4 {
+			t.Fatalf("expected %d messages, got %d", len(prompts[0])+4, len(last))
+		}
+
+		history := last[len(prompts[0]):]
+		if history[0].Content != responses[0] || history[2].Content != responses[1] {
+			t.Errorf("expected both responses, got %+v", history)
+		}
+		if !strings.Contains(history[1].Content, "$.sentiment: expected a string, got a number") {
+			t.Errorf("expected the first reason, got %s", history[1].Content)
+		}
+		if !strings.Contains(history[3].Content, "$.mood: unknown property") {
+			t.Errorf("expected the second reason, got %s", history[3].Content)
+		}
+	})
+
+	t.Run("it sends only the schema and the last response when compact", func(t *testing.T) {
+		prompts := execute(t, RepairCompact, responses...)
+
+		last := prompts[2]
+		if len(last) != 2 {
+			t.Fatalf("expected 2 messages, got %d", len(last))
+		}
+		if last[0] != prompts[0][0] {
+			t.Errorf("expected the schema message, got %+v", last[0])
+		}
+
+		expected := "The following JSON object is invalid:\n" +
+			`{"mood": "positive"}` + "\n" +
+			"for the following reason:\n" +
+			"$.sentiment: missing required property\n" +
+			"$.mood: unknown property\n" +
+			"The following is a revised JSON object:\n"
+		if last[1].Role != RoleUser || last[1].Content != expected {
+			t.Errorf("expected user message:\n%s\ngot %s message:\n%s", expected, last[1].Role, last[1].Content)
+		}
+		if strings.Contains(last[1].Content, "It was great") {
+			t.Error("expected the user request to be left out")
+		}
+	})
+
+	t.Run("it rejects unknown strategies", func(t *testing.T) {
+		p := NewPrompt[Result](mockModelClient{}, "", PromptRepairStrategy[Result](RepairStrategy{}))
+		if _, err := p.Execute(context.Background()); err == nil {
+			t.Error("expected err to be non-nil")
+		}
+	})
+
+	t.Run("it sends repair requests as user messages by default", func(t *testing.T) {
+		prompts := execute(t, RepairLastOnly, responses[1:]...)
+
+		repair := prompts[1][len(prompts[1])-1]
+		if repair.Role != RoleUser {
+			t.Errorf("expected a user message, got %s", repair.Role)
+		}
+	})
+
+	t.Run("it only sends a leading system message", func(t *testing.T) {
+		prompts := execute(t, RepairLastOnly, responses[1:]...)
+
+		var roles []string
+		for _, m := range prompts[1] {
+			roles = append(roles, m.Role.String())
+		}
+		if got := strings.Join(roles, ", "); got != "system, user, assistant, user" {
+			t.Errorf("expected roles system, user, assistant, user, got %s", got)
+		}
+		if !strings.Contains(prompts[1][1].Content, userRequestPromptInstructions) {
+			t.Errorf("expected the user message to contain the instructions, got %s", prompts[1][1].Content)
+		}
+	})
+}
+
+type roleModelClient struct {
+	funcModelClient
+	role Role
+}
+
+func (c roleModelClient) RepairRole() Role {
+	return c.role
+}
+
+func TestRepairMessage(t *testing.T) {
+	type Result struct {
+		Sentiment string `json:"sentiment"`
+	}
+
+	// repair returns the message asking the model to fix its first response.
+	repair := func(t *testing.T, m client, opts ...opt[Result]) Message {
+		t.Helper()
+
+		var prompts [][]Message
+		do := func(ctx context.Context, prompt []Message) (string, error) {
+			prompts = append(prompts, prompt)
+			if len(prompts) == 1 {
+				return `{"sentiment": 1}`, nil
+			}
+			return `{"sentiment": "positive"}`, nil
+		}
+		switch c := m.(type) {
+		case roleModelClient:
+			c.funcModelClient = do
+			m = c
+		default:
+			m = funcModelClient(do)
+		}
+
+		if _, err := NewPrompt[Result](m, "", opts...).Execute(context.Background()); err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		return prompts[1][len(prompts[1])-1]
+	}
+
+	t.Run("it uses the role set on the prompt", func(t *testing.T) {
+		msg := repair(t, nil, PromptRepairRole[Result](RoleSystem))
+		if msg.Role != RoleSystem {
+			t.Errorf("expected a system message, got %s", msg.Role)
+		}
+	})
+
+	t.Run("it uses the role requested by the model client", func(t *testing.T) {
+		msg := repair(t, roleModelClient{role: RoleSystem})
+		if msg.Role != RoleSystem {
+			t.Errorf("expected a system message, got %s", msg.Role)
+		}
+
+		msg = repair(t, roleModelClient{role: RoleSystem}, PromptRepairRole[Result](RoleUser))
+		if msg.Role != RoleUser {
+			t.Errorf("expected the prompt role to take precedence, got %s", msg.Role)
+		}
+	})
+
+	t.Run("it writes the message with the template of the prompt", func(t *testing.T) {
+		tmpl := "Your answer {{.Response}} is not a valid {{.Object}}:\n{{.Reason}}\nPlease try again."
+		msg := repair(t, nil, PromptRepairTemplate[Result](tmpl))
+
+		expected := "Your answer {\"sentiment\": 1} is not a valid JSON object:\n" +
+			"$.sentiment: expected a string, got a number\nPlease try again."
+		if msg.Content != expected {
+			t.Errorf("expected %q, got %q", expected, msg.Content)
+		}
+	})
+
+	t.Run("it rejects invalid templates", func(t *testing.T) {
+		p := NewPrompt[Result](mockModelClient{}, "", PromptRepairTemplate[Result]("{{.Reason"))
+		_, err := p.Execute(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "invalid repair template") {
+			t.Errorf("expected an invalid repair template error, got %v", err)
+		}
+	})
+}
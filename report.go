@@ -0,0 +1,35 @@
+package typechat
+
+import "errors"
+
+// Attempt is a single request made to the model while executing a prompt, including requests that failed and were
+// retried by the transport retry policy.
+type Attempt struct {
+	// Prompt is the conversation sent to the model. After a rejected response it ends with the repair request.
+	Prompt   []Message
+	Response string
+	// Fixes are the mistakes fixed in the response by the lenient JSON pass.
+	Fixes []JSONFix
+	// Err is why the response was rejected or the model could not be reached, nil for an accepted response.
+	Err error
+}
+
+// Report records the attempts made by the last call to Execute or CreateProgram, to help debugging prompts that need
+// repairs.
+type Report struct {
+	Attempts []Attempt
+}
+
+// Repairs returns the number of times the model was asked to fix its response.
+func (r *Report) Repairs() int {
+	var repairs int
+	for i, a := range r.Attempts {
+		var parseErr *ParseError
+		var validationErr *ValidationError
+		if i < len(r.Attempts)-1 && (errors.As(a.Err, &parseErr) || errors.As(a.Err, &validationErr)) {
+			repairs++
+		}
+	}
+
+	return repairs
+}
@@ -0,0 +1,316 @@
+package typechat
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+)
+
+type exprKind int
+
+const (
+	exprScalar exprKind = iota
+	exprAny
+	exprSlice
+	exprMap
+	exprNamed
+)
+
+// typeExpr is a type as it is used by a field, slice element or map value. Named types refer to their declaration
+// in the schema instead of repeating it.
+type typeExpr struct {
+	kind     exprKind
+	scalar   reflect.Kind // exprScalar
+	elem     *typeExpr    // exprSlice element or exprMap value
+	key      *typeExpr    // exprMap key
+	decl     *typeDecl    // exprNamed
+	nullable bool         // the Go type is a pointer
+	format   string       // encoding of a string scalar, "base64" for byte slices
+}
+
+type declKind int
+
+const (
+	declStruct declKind = iota
+	declScalar
+	declAlias
+)
+
+// typeDecl is a named type declared once in the schema. Anonymous structs are also represented as declarations, but
+// with an empty name and are written inline where they are used.
+type typeDecl struct {
+	kind        declKind
+	name        string
+	typ         reflect.Type
+	description string
+
+	fields    []fieldDecl   // declStruct
+	scalar    reflect.Kind  // declScalar
+	format    string        // declScalar, JSON Schema format of well-known types such as date-time
+	unbounded bool          // declScalar, integers of any size are accepted
+	enum      []enumLiteral // declScalar, nil unless the type is an enum
+	alias     *typeExpr     // declAlias, a named type described by another type
+}
+
+// fieldDecl is a property of a struct declaration.
+type fieldDecl struct {
+	name        string // JSON property name
+	goName      string
+	typ         *typeExpr
+	optional    bool
+	quoted      bool
+	description string
+	constraints *constraints // nil unless the field has a tc tag
+}
+
+// schema is the registry of type declarations reachable from one or more root types. Each named type is declared
+// once, after the types it depends on, and recursive types refer back to their own declaration.
+type schema struct {
+	decls  []*typeDecl
+	byType map[reflect.Type]*typeDecl
+	names  map[string]reflect.Type
+}
+
+func newSchema() *schema {
+	return &schema{
+		byType: make(map[reflect.Type]*typeDecl),
+		names:  make(map[string]reflect.Type),
+	}
+}
+
+// expr returns the type expression for t, declaring any named types it refers to.
+func (s *schema) expr(t reflect.Type, allowEmptyInterface bool) (*typeExpr, error) {
+	t, ptr := indirect(t)
+	if disallowedField(t, allowEmptyInterface) {
+		return nil, fmt.Errorf("disallowed type %s", t.Kind())
+	}
+
+	e := &typeExpr{nullable: ptr}
+	_, custom := customSchema(t)
+	w, wellKnown := lookupWellKnown(t)
+	switch k := t.Kind(); {
+	case !custom && wellKnown && w.scalar == reflect.Interface:
+		e.kind = exprAny
+	case custom || wellKnown:
+		decl, err := s.declare(t)
+		if err != nil {
+			return nil, err
+		}
+		e.kind = exprNamed
+		e.decl = decl
+	case byteSlice(t):
+		e.kind = exprScalar
+		e.scalar = reflect.String
+		e.format = "base64"
+	case k == reflect.Interface:
+		e.kind = exprAny
+	case k == reflect.Slice || k == reflect.Array:
+		elem, err := s.expr(t.Elem(), true)
+		if err != nil {
+			return nil, fmt.Errorf("slice element: %w", err)
+		}
+		e.kind = exprSlice
+		e.elem = elem
+	case k == reflect.Map:
+		key := t.Key()
+		if _, textKey := lookupWellKnown(key); key.Kind() == reflect.Pointer || compositeField(key.Kind()) && !textKey {
+			return nil, fmt.Errorf("map key has disallowed type %s", key.Kind())
+		}
+		keyExpr, err := s.expr(key, false)
+		if err != nil {
+			return nil, fmt.Errorf("map key: %w", err)
+		}
+		value, err := s.expr(t.Elem(), false)
+		if err != nil {
+			return nil, fmt.Errorf("map value: %w", err)
+		}
+		e.kind = exprMap
+		e.key = keyExpr
+		e.elem = value
+	case k == reflect.Struct || namedScalar(t):
+		decl, err := s.declare(t)
+		if err != nil {
+			return nil, err
+		}
+		e.kind = exprNamed
+		e.decl = decl
+	default:
+		e.kind = exprScalar
+		e.scalar = k
+	}
+
+	return e, nil
+}
+
+// declare returns the declaration of the named type t, adding it and its dependencies to the schema the first time
+// t is seen.
+func (s *schema) declare(t reflect.Type) (*typeDecl, error) {
+	if d, ok := s.byType[t]; ok {
+		return d, nil
+	}
+
+	d := &typeDecl{
+		typ:         t,
+		description: typeDescription(t),
+	}
+	if t.Name() != "" {
+		d.name = s.uniqueName(t)
+		// Register before walking the fields so recursive references resolve to this declaration.
+		s.byType[t] = d
+	}
+
+	custom, hasCustom := customSchema(t)
+	w, wellKnown := lookupWellKnown(t)
+	var enumErr error
+	switch {
+	case hasCustom:
+		if err := s.declareLike(d, custom); err != nil {
+			delete(s.byType, t)
+			return nil, err
+		}
+	case wellKnown:
+		d.kind = declScalar
+		d.scalar = w.scalar
+		d.format = w.format
+		d.unbounded = w.unbounded
+		if d.description == "" {
+			d.description = w.description
+		}
+		d.enum, enumErr = enumDecl(t)
+	case t.Kind() == reflect.Struct:
+		if err := s.declareFields(d, t); err != nil {
+			delete(s.byType, t)
+			return nil, err
+		}
+	default:
+		d.kind = declScalar
+		d.scalar = t.Kind()
+		d.enum, enumErr = enumDecl(t)
+	}
+
+	if enumErr != nil {
+		delete(s.byType, t)
+		return nil, fmt.Errorf("enum %s: %w", t.Name(), enumErr)
+	}
+
+	if d.name != "" {
+		s.decls = append(s.decls, d)
+	}
+
+	return d, nil
+}
+
+func (s *schema) declareFields(d *typeDecl, t reflect.Type) error {
+	d.kind = declStruct
+	for _, sf := range structFields(t) {
+		f, err := s.field(sf)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", sf.field.Name, err)
+		}
+		d.fields = append(d.fields, f)
+	}
+
+	return nil
+}
+
+// declareLike declares d with the structure of the type of custom.Like.
+func (s *schema) declareLike(d *typeDecl, custom Schema) error {
+	if custom.Description != "" {
+		d.description = custom.Description
+	}
+
+	if custom.Like == nil {
+		d.kind = declAlias
+		d.alias = &typeExpr{kind: exprAny}
+		return nil
+	}
+
+	like := reflect.TypeOf(custom.Like)
+	if like == d.typ {
+		return fmt.Errorf("schema of %s must be described by another type", d.typ)
+	}
+
+	if _, custom := customSchema(like); !custom && like.Kind() == reflect.Struct {
+		if _, wellKnown := lookupWellKnown(like); !wellKnown {
+			return s.declareFields(d, like)
+		}
+	}
+
+	e, err := s.expr(like, true)
+	if err != nil {
+		return fmt.Errorf("schema of %s: %w", d.typ, err)
+	}
+
+	if e.kind == exprScalar && !e.nullable && e.format == "" {
+		d.kind = declScalar
+		d.scalar = e.scalar
+		d.enum, err = enumDecl(d.typ)
+		if err != nil {
+			return fmt.Errorf("enum %s: %w", d.typ.Name(), err)
+		}
+		return nil
+	}
+
+	d.kind = declAlias
+	d.alias = e
+
+	return nil
+}
+
+func (s *schema) field(sf structField) (fieldDecl, error) {
+	typ, err := s.expr(sf.field.Type, false)
+	if err != nil {
+		return fieldDecl{}, err
+	}
+
+	c, err := parseConstraints(sf.field.Tag.Get("tc"), typ)
+	if err != nil {
+		return fieldDecl{}, err
+	}
+
+	return fieldDecl{
+		name:        sf.name,
+		goName:      sf.field.Name,
+		typ:         typ,
+		optional:    typ.nullable || sf.omitEmpty,
+		quoted:      sf.quoted,
+		description: sf.field.Tag.Get("description"),
+		constraints: c,
+	}, nil
+}
+
+// rollback removes the declarations added after the first n, such as the dependencies of a type that failed to be
+// declared, and frees their names.
+func (s *schema) rollback(n int) {
+	for _, d := range s.decls[n:] {
+		delete(s.byType, d.typ)
+	}
+	s.decls = s.decls[:n]
+
+	for name, t := range s.names {
+		if _, ok := s.byType[t]; !ok {
+			delete(s.names, name)
+		}
+	}
+}
+
+var qualifiedName = regexp.MustCompile(`[\w./-]*[/.]`)
+
+// uniqueName returns the name used to declare t. Package paths are removed from the type arguments of generic types
+// and distinct types sharing a name, such as types from different packages, are numbered.
+func (s *schema) uniqueName(t reflect.Type) string {
+	base := qualifiedName.ReplaceAllString(t.Name(), "")
+
+	name := base
+	for i := 2; ; i++ {
+		other, ok := s.names[name]
+		if !ok || other == t {
+			break
+		}
+		name = base + strconv.Itoa(i)
+	}
+	s.names[name] = t
+
+	return name
+}
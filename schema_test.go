@@ -0,0 +1,144 @@
+package typechat
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testNode struct {
+	Name     string
+	Children []testNode
+	Parent   *testNode
+}
+
+type testComment struct {
+	Text    string
+	Replies []testThread
+}
+
+type testThread struct {
+	Comments []testComment
+}
+
+func TestSchema(t *testing.T) {
+	t.Run("it declares types used more than once a single time", func(t *testing.T) {
+		type Address struct {
+			City string
+		}
+
+		type Foo struct {
+			Home    Address
+			Work    *Address
+			Others  []Address
+			ByLabel map[string]Address
+		}
+
+		_, def, err := structDef(reflect.TypeOf(Foo{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `
+type Address struct {
+	City string
+}
+type Foo struct {
+	Home Address
+	Work *Address // optional
+	Others []Address
+	ByLabel map[string]Address
+}`
+		assertNameDefOuptut(t, def, expected)
+	})
+
+	t.Run("it supports self-referential types", func(t *testing.T) {
+		_, def, err := structDef(reflect.TypeOf(testNode{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `
+type testNode struct {
+	Name string
+	Children []testNode
+	Parent *testNode // optional
+}`
+		assertNameDefOuptut(t, def, expected)
+	})
+
+	t.Run("it supports mutually recursive types", func(t *testing.T) {
+		_, def, err := structDef(reflect.TypeOf(testThread{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `
+type testComment struct {
+	Text string
+	Replies []testThread
+}
+type testThread struct {
+	Comments []testComment
+}`
+		assertNameDefOuptut(t, def, expected)
+	})
+
+	t.Run("it renders anonymous structs inline", func(t *testing.T) {
+		type Foo struct {
+			Point struct {
+				X int
+				Y int
+			}
+		}
+
+		_, def, err := structDef(reflect.TypeOf(Foo{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `
+type Foo struct {
+	Point struct {
+		X int
+		Y int
+	}
+}`
+		assertNameDefOuptut(t, def, expected)
+	})
+
+	t.Run("it numbers distinct types sharing a name", func(t *testing.T) {
+		s := newSchema()
+		if _, err := s.declare(reflect.TypeOf(testNode{})); err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		// a local type named testNode, distinct from the package level one
+		type testNode struct {
+			Value int
+		}
+		d, err := s.declare(reflect.TypeOf(testNode{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if d.name != "testNode2" {
+			t.Errorf("expected name to be testNode2, got %s", d.name)
+		}
+		if len(s.decls) != 2 {
+			t.Errorf("expected 2 declarations, got %d", len(s.decls))
+		}
+	})
+
+	t.Run("it removes package paths from generic type names", func(t *testing.T) {
+		s := newSchema()
+		d, err := s.declare(reflect.TypeOf(testPage[testNode]{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if d.name != "testPage[testNode]" {
+			t.Errorf("expected name to be testPage[testNode], got %s", d.name)
+		}
+	})
+}
+
+type testPage[T any] struct {
+	Items []T
+}
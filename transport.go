@@ -0,0 +1,107 @@
+package typechat
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// TransportRetry is the policy for retrying requests the model client failed with a retryable error, such as a rate
+// limit or an unavailable provider. It is separate from PromptRetries, which repairs responses that could not be
+// parsed.
+type TransportRetry struct {
+	// MaxAttempts is the number of requests made before giving up, the first one included. 1 or less disables retries.
+	MaxAttempts int
+	// BaseDelay is the upper bound of the first backoff, doubled after every attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff and any delay requested by the provider.
+	MaxDelay time.Duration
+}
+
+// DefaultTransportRetry is the policy used by prompts unless PromptTransportRetry is set.
+var DefaultTransportRetry = TransportRetry{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+// delay returns how long to wait before the given retry, starting at 1, using exponential backoff with full jitter
+// unless the error asks for a specific delay.
+func (r TransportRetry) delay(retry int, err error) time.Duration {
+	var ra interface{ RetryAfter() time.Duration }
+	if errors.As(err, &ra) && ra.RetryAfter() > 0 {
+		if d := ra.RetryAfter(); r.MaxDelay <= 0 || d < r.MaxDelay {
+			return d
+		}
+		return r.MaxDelay
+	}
+
+	backoff := r.BaseDelay
+	for i := 1; i < retry && (r.MaxDelay <= 0 || backoff < r.MaxDelay); i++ {
+		backoff *= 2
+	}
+	if r.MaxDelay > 0 && backoff > r.MaxDelay {
+		backoff = r.MaxDelay
+	}
+	if backoff <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// retryableError marks an error of the model client as transient.
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+func (e *retryableError) Retryable() bool {
+	return true
+}
+
+func (e *retryableError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// RetryableError marks err as a transient failure that the prompt retries according to its TransportRetry policy.
+// retryAfter is the delay requested by the provider, such as the Retry-After header of an HTTP response, or zero to
+// use the backoff of the policy.
+//
+// Model clients can also return their own error types implementing Retryable() bool and optionally
+// RetryAfter() time.Duration.
+func RetryableError(err error, retryAfter time.Duration) error {
+	return &retryableError{err: err, retryAfter: retryAfter}
+}
+
+// retryable reports whether err is marked as retryable. Cancellations and expired deadlines never are.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var r interface{ Retryable() bool }
+	return errors.As(err, &r) && r.Retryable()
+}
+
+// wait sleeps for d or until ctx is done.
+func wait(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,145 @@
+package typechat
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTransportRetry(t *testing.T) {
+	type Result struct {
+		Sentiment string `json:"sentiment"`
+	}
+
+	fast := TransportRetry{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	t.Run("it retries retryable errors", func(t *testing.T) {
+		var calls int
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			calls++
+			if calls < 3 {
+				return "", RetryableError(errors.New("503 service unavailable"), 0)
+			}
+			return `{"sentiment": "positive"}`, nil
+		})
+
+		var report Report
+		p := NewPrompt[Result](m, "", PromptTransportRetry[Result](fast), PromptReport[Result](&report))
+		result, err := p.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if result.Sentiment != "positive" {
+			t.Errorf("expected positive, got %s", result.Sentiment)
+		}
+		if calls != 3 {
+			t.Errorf("expected 3 calls, got %d", calls)
+		}
+		if len(report.Attempts) != 3 || report.Repairs() != 0 {
+			t.Errorf("expected 3 attempts and no repairs, got %d and %d", len(report.Attempts), report.Repairs())
+		}
+	})
+
+	t.Run("it gives up after the maximum number of attempts", func(t *testing.T) {
+		var calls int
+		cause := errors.New("429 too many requests")
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			calls++
+			return "", RetryableError(cause, 0)
+		})
+
+		p := NewPrompt[Result](m, "", PromptTransportRetry[Result](fast))
+		_, err := p.Execute(context.Background())
+		var transportErr *TransportError
+		if !errors.As(err, &transportErr) || !errors.Is(err, cause) {
+			t.Errorf("expected a TransportError wrapping %v, got %v", cause, err)
+		}
+		if calls != 3 {
+			t.Errorf("expected 3 calls, got %d", calls)
+		}
+	})
+
+	t.Run("it does not retry other errors", func(t *testing.T) {
+		var calls int
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			calls++
+			return "", errors.New("401 unauthorized")
+		})
+
+		p := NewPrompt[Result](m, "", PromptTransportRetry[Result](fast))
+		if _, err := p.Execute(context.Background()); err == nil {
+			t.Fatal("expected err to be non-nil")
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 call, got %d", calls)
+		}
+	})
+
+	t.Run("it does not retry when the deadline would pass first", func(t *testing.T) {
+		var calls int
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			calls++
+			return "", RetryableError(errors.New("429 too many requests"), time.Minute)
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		policy := TransportRetry{MaxAttempts: 3, MaxDelay: time.Hour}
+		start := time.Now()
+		if _, err := NewPrompt[Result](m, "", PromptTransportRetry[Result](policy)).Execute(ctx); err == nil {
+			t.Fatal("expected err to be non-nil")
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 call, got %d", calls)
+		}
+		if time.Since(start) > 500*time.Millisecond {
+			t.Errorf("expected to return without waiting, took %s", time.Since(start))
+		}
+	})
+
+	t.Run("it stops waiting when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			cancel()
+			return "", RetryableError(errors.New("503 service unavailable"), time.Hour)
+		})
+
+		policy := TransportRetry{MaxAttempts: 3, MaxDelay: time.Hour}
+		_, err := NewPrompt[Result](m, "", PromptTransportRetry[Result](policy)).Execute(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected err to be context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("it backs off exponentially with jitter", func(t *testing.T) {
+		policy := TransportRetry{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+		err := errors.New("503 service unavailable")
+
+		bounds := map[int]time.Duration{
+			1:  100 * time.Millisecond,
+			2:  200 * time.Millisecond,
+			3:  400 * time.Millisecond,
+			10: time.Second,
+		}
+		for retry, max := range bounds {
+			for i := 0; i < 20; i++ {
+				if d := policy.delay(retry, err); d < 0 || d > max {
+					t.Errorf("expected retry %d to wait at most %s, got %s", retry, max, d)
+				}
+			}
+		}
+	})
+
+	t.Run("it honors the delay requested by the provider", func(t *testing.T) {
+		policy := TransportRetry{BaseDelay: 100 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+		if d := policy.delay(1, RetryableError(errors.New("429"), 3*time.Second)); d != 3*time.Second {
+			t.Errorf("expected 3s, got %s", d)
+		}
+		if d := policy.delay(1, RetryableError(errors.New("429"), time.Minute)); d != 5*time.Second {
+			t.Errorf("expected the delay to be capped at 5s, got %s", d)
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 )
 
 type Role struct {
@@ -55,45 +56,140 @@ type Prompt[T any] struct {
 	model  client
 	prompt string
 
-	retries int
+	retries    int
+	dialect    SchemaDialect
+	validators []Validator[T]
+	extractor  Extractor
+	lenient    bool
+	onJSONFix  func(fixes []JSONFix)
+	report     *Report
+	transport  TransportRetry
+	repair     repairOptions
 }
 
 type opt[T any] func(*Prompt[T])
 
-// PromptRetries sets the number of times to retry parsing errors.
+// PromptRetries sets the number of times the model is asked to fix a response that cannot be parsed or fails
+// validation, defaults to 1. Zero disables repairs.
 func PromptRetries[T any](retries int) opt[T] {
 	return func(t *Prompt[T]) {
 		t.retries = retries
 	}
 }
 
+// PromptSchemaDialect sets the language used to describe types to the model, defaults to DialectGo.
+func PromptSchemaDialect[T any](dialect SchemaDialect) opt[T] {
+	return func(t *Prompt[T]) {
+		t.dialect = dialect
+	}
+}
+
+// PromptExtractor sets how the JSON value is found in the responses of the model, defaults to ExtractJSON.
+func PromptExtractor[T any](extractor Extractor) opt[T] {
+	return func(t *Prompt[T]) {
+		t.extractor = extractor
+	}
+}
+
+// PromptLenientJSON sets whether common mistakes in responses, such as trailing commas, comments, single quotes,
+// unquoted keys and truncated output, are fixed before decoding instead of asking the model to fix them. Defaults to
+// true.
+func PromptLenientJSON[T any](enabled bool) opt[T] {
+	return func(t *Prompt[T]) {
+		t.lenient = enabled
+	}
+}
+
+// PromptOnJSONFix sets a function called with the fixes applied to a response by the lenient JSON pass.
+func PromptOnJSONFix[T any](report func(fixes []JSONFix)) opt[T] {
+	return func(t *Prompt[T]) {
+		t.onJSONFix = report
+	}
+}
+
+// PromptReport sets a report that records every attempt made by Execute and CreateProgram. It is reset by each call, so
+// it should not be shared by concurrent calls.
+func PromptReport[T any](report *Report) opt[T] {
+	return func(t *Prompt[T]) {
+		t.report = report
+	}
+}
+
+// PromptTransportRetry sets how requests failing with a retryable error are retried, defaults to
+// DefaultTransportRetry.
+func PromptTransportRetry[T any](policy TransportRetry) opt[T] {
+	return func(t *Prompt[T]) {
+		t.transport = policy
+	}
+}
+
+// PromptRepairStrategy sets which previous responses are sent to the model when it is asked to fix a response,
+// defaults to RepairLastOnly.
+func PromptRepairStrategy[T any](strategy RepairStrategy) opt[T] {
+	return func(t *Prompt[T]) {
+		t.repair.strategy = strategy
+	}
+}
+
+// PromptRepairRole sets the role of the messages asking the model to fix a response. Defaults to RoleUser, which every
+// chat API accepts after an assistant message, unless the model client implements RepairRoler.
+func PromptRepairRole[T any](role Role) opt[T] {
+	return func(t *Prompt[T]) {
+		t.repair.role = role
+	}
+}
+
+// PromptRepairTemplate sets the text/template used to write the messages asking the model to fix a response. It is
+// executed with a RepairData.
+func PromptRepairTemplate[T any](tmpl string) opt[T] {
+	return func(t *Prompt[T]) {
+		t.repair.template = tmpl
+	}
+}
+
 // NewPrompt creates a new Prompt[T] with the given modelClient, prompt and options.
 func NewPrompt[T any](model client, prompt string, opts ...opt[T]) *Prompt[T] {
 	t := &Prompt[T]{
-		model:  model,
-		prompt: prompt,
+		model:     model,
+		prompt:    prompt,
+		dialect:   DialectGo,
+		retries:   1,
+		extractor: ExtractorFunc(ExtractJSON),
+		lenient:   true,
+		transport: DefaultTransportRetry,
+		repair:    repairOptions{strategy: RepairLastOnly},
 	}
 	for _, opt := range opts {
 		opt(t)
 	}
-	if t.retries <= 0 {
-		t.retries = 1
+	if t.repair.role == (Role{}) {
+		t.repair.role = RoleUser
+		if r, ok := model.(RepairRoler); ok {
+			t.repair.role = r.RepairRole()
+		}
+	}
+	if t.retries < 0 {
+		t.retries = 0
 	}
 
 	return t
 }
 
-// Execute executes the user prompt and parses the result into the given structure. Parsing errors are retried up to
-// Prompt.retries times.
+// Execute executes the user prompt and parses the result into the given structure. The result is checked by the
+// Validate method of T and the validators of the prompt. Responses failing to parse or validate are sent back to the
+// model to be fixed, up to Prompt.retries times.
 func (p *Prompt[T]) Execute(ctx context.Context) (T, error) {
 	var result T
 
-	b, err := newBuilder[T](promptUserRequest, p.prompt)
+	b, err := newBuilder[T](promptUserRequest, p.prompt, p.dialect, p.repair)
 	if err != nil {
 		return result, fmt.Errorf("failed to create prompt builder: %w", err)
 	}
 
-	if err := p.exec(ctx, b, &result); err != nil {
+	validate := func(ctx context.Context) error {
+		return p.validate(ctx, &result)
+	}
+	if err := p.exec(ctx, b, &result, validate); err != nil {
 		return result, fmt.Errorf("failed to execute prompt: %w", err)
 	}
 
@@ -102,49 +198,120 @@ func (p *Prompt[T]) Execute(ctx context.Context) (T, error) {
 
 // CreateProgram executes the prompt with the provided API and parses the result into a typechat.Program to be used
 // by callers. Refer to the Program struct for structure. Steps will refer to methods provided in the API interface.
-// Parsing errors are retried up to Prompt.retries times.
+// Programs calling unknown methods, with arguments of the wrong types or with invalid references are sent back to
+// the model to be fixed like responses failing to parse, up to Prompt.retries times.
 func (p *Prompt[T]) CreateProgram(ctx context.Context) (Program, error) {
 	var program Program
 
-	b, err := newBuilder[T](promptProgram, p.prompt)
+	b, err := newBuilder[T](promptProgram, p.prompt, p.dialect, p.repair)
 	if err != nil {
 		return program, fmt.Errorf("failed to create prompt builder: %w", err)
 	}
 
-	if err := p.exec(ctx, b, &program); err != nil {
+	validate := func(context.Context) error {
+		return b.validate(&program)
+	}
+	if err := p.exec(ctx, b, &program, validate); err != nil {
 		return program, fmt.Errorf("failed to execute prompt: %w", err)
 	}
 
 	return program, nil
 }
 
-func (p *Prompt[T]) exec(ctx context.Context, b *builder[T], output any) error {
+// exec sends the prompt to the model and asks it to fix its response until the response can be parsed into output or
+// the repairs run out. validate, if not nil, is called after output has been decoded to check it further.
+func (p *Prompt[T]) exec(ctx context.Context, b *builder[T], output any, validate func(context.Context) error) error {
+	if p.report != nil {
+		*p.report = Report{}
+	}
+
 	prompt, err := b.prompt()
 	if err != nil {
 		return fmt.Errorf("failed to build prompt: %w", err)
 	}
 
-	var failedParsing bool
-	for i := 0; i < p.retries; i++ {
-		resp, err := p.model.Do(ctx, prompt)
+	var failures []error
+	for repairs := 0; ; repairs++ {
+		resp, err := p.do(ctx, prompt)
 		if err != nil {
 			return err
 		}
 
-		if err := json.Unmarshal([]byte(resp), output); err != nil {
-			prompt, err = b.repair(resp, err)
-			if err != nil {
-				return fmt.Errorf("failed to repair prompt: %w", err)
+		// reason is sent to the model for repair, failure is the typed error returned to the caller
+		fixes, reason := p.parse(b, resp, output)
+		var failure error
+		if reason != nil {
+			failure = &ParseError{Response: resp, Err: reason}
+		} else if validate != nil {
+			if reason = validate(ctx); reason != nil {
+				failure = &ValidationError{Response: resp, Err: reason}
 			}
+		}
+		p.record(Attempt{Prompt: prompt, Response: resp, Fixes: fixes, Err: failure})
+		if failure == nil {
+			return nil
+		}
+
+		failures = append(failures, failure)
+		if repairs == p.retries {
+			return &RetriesExhaustedError{Retries: repairs, LastResponse: resp, Errors: failures}
+		}
 
-			failedParsing = true
-			continue
+		prompt, err = b.repair(resp, reason)
+		if err != nil {
+			return fmt.Errorf("failed to repair prompt: %w", err)
 		}
 	}
+}
 
-	if failedParsing {
-		return fmt.Errorf("failed to parse prompt response with %d retries", p.retries)
+// do sends the prompt to the model, retrying retryable errors according to the transport retry policy. Retries that
+// could not complete before the deadline of ctx are not attempted.
+func (p *Prompt[T]) do(ctx context.Context, prompt []Message) (string, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := p.model.Do(ctx, prompt)
+		if err == nil {
+			return resp, nil
+		}
+
+		err = &TransportError{Err: err}
+		p.record(Attempt{Prompt: prompt, Err: err})
+		if attempt >= p.transport.MaxAttempts || !retryable(err) {
+			return "", err
+		}
+
+		delay := p.transport.delay(attempt, err)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return "", err
+		}
+		if err := wait(ctx, delay); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (p *Prompt[T]) record(a Attempt) {
+	if p.report != nil {
+		p.report.Attempts = append(p.report.Attempts, a)
+	}
+}
+
+// parse finds the JSON value in the response, fixes it if it is not valid JSON and decodes it into output.
+func (p *Prompt[T]) parse(b *builder[T], resp string, output any) ([]JSONFix, error) {
+	data, err := p.extractor.Extract(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []JSONFix
+	if p.lenient && !json.Valid([]byte(data)) {
+		// When the response cannot be fixed, decoding the original reports what is wrong with it.
+		if fixed, fixes, err := fixJSON(data); err == nil {
+			data, applied = fixed, fixes
+			if p.onJSONFix != nil {
+				p.onJSONFix(fixes)
+			}
+		}
 	}
 
-	return nil
+	return applied, b.parse(data, output)
 }
@@ -3,6 +3,8 @@ package typechat
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -15,6 +17,12 @@ func (m mockModelClient) Do(ctx context.Context, prompt []Message) (response str
 	return m.response, m.err
 }
 
+type funcModelClient func(ctx context.Context, prompt []Message) (string, error)
+
+func (f funcModelClient) Do(ctx context.Context, prompt []Message) (string, error) {
+	return f(ctx, prompt)
+}
+
 func TestTypeChat(t *testing.T) {
 	type Result struct {
 		Sentiment string `json:"sentiment"`
@@ -35,6 +43,30 @@ func TestTypeChat(t *testing.T) {
 		}
 	})
 
+	t.Run("it should accept missing or null pointer fields", func(t *testing.T) {
+		type Address struct {
+			City string
+		}
+		type Result struct {
+			Name    string
+			Age     *int
+			Address *Address
+		}
+
+		ctx := context.Background()
+		m := mockModelClient{
+			response: `{"Name": "Jane", "Address": null}`,
+		}
+		p := NewPrompt[Result](m, "Jane has no known address")
+		result, err := p.Execute(ctx)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if result.Age != nil || result.Address != nil {
+			t.Errorf("Expected nil pointers, got %v and %v", result.Age, result.Address)
+		}
+	})
+
 	t.Run("it should configure retries", func(t *testing.T) {
 		p := NewPrompt[Result](nil, "", PromptRetries[Result](5))
 		if p.retries != 5 {
@@ -89,4 +121,98 @@ func TestTypeChat(t *testing.T) {
 			t.Errorf("Expected Step2, got %v", result.Steps[1].Name)
 		}
 	})
+
+	t.Run("it should stop as soon as a repaired response is parsed", func(t *testing.T) {
+		responses := []string{`{"sentiment": 1}`, `{"sentiment": "positive"}`, `{"sentiment": "negative"}`}
+		var calls int
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			calls++
+			return responses[calls-1], nil
+		})
+
+		p := NewPrompt[Result](m, "", PromptRetries[Result](2))
+		result, err := p.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if result.Sentiment != "positive" {
+			t.Errorf("Expected positive, got %v", result.Sentiment)
+		}
+		if calls != 2 {
+			t.Errorf("Expected 2 calls, got %v", calls)
+		}
+	})
+
+	t.Run("it should make one attempt per repair after the first", func(t *testing.T) {
+		for _, retries := range []int{0, 1, 3} {
+			var calls int
+			m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+				calls++
+				return `{}`, nil
+			})
+
+			p := NewPrompt[Result](m, "", PromptRetries[Result](retries))
+			_, err := p.Execute(context.Background())
+			if err == nil {
+				t.Fatalf("Expected an error with %d retries", retries)
+			}
+			if !strings.Contains(err.Error(), "$.sentiment: missing required property") {
+				t.Errorf("Expected the last parsing error, got %v", err)
+			}
+			if calls != retries+1 {
+				t.Errorf("Expected %d calls with %d retries, got %v", retries+1, retries, calls)
+			}
+		}
+	})
+
+	t.Run("it should report every attempt", func(t *testing.T) {
+		responses := []string{`not json`, `{'sentiment': 'positive'}`}
+		var calls int
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			calls++
+			return responses[calls-1], nil
+		})
+
+		var report Report
+		p := NewPrompt[Result](m, "", PromptReport[Result](&report))
+		if _, err := p.Execute(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if len(report.Attempts) != 2 || report.Repairs() != 1 {
+			t.Fatalf("Expected 2 attempts and 1 repair, got %v and %v", len(report.Attempts), report.Repairs())
+		}
+		first, second := report.Attempts[0], report.Attempts[1]
+		if first.Response != "not json" || !errors.Is(first.Err, ErrNoJSON) {
+			t.Errorf("Expected the first attempt to fail without JSON, got %q and %v", first.Response, first.Err)
+		}
+		if len(second.Prompt) != len(first.Prompt)+2 {
+			t.Errorf("Expected the repair prompt to add 2 messages, got %v", len(second.Prompt)-len(first.Prompt))
+		}
+		if second.Err != nil || len(second.Fixes) != 1 || second.Fixes[0] != JSONFixSingleQuotes {
+			t.Errorf("Expected the second attempt to succeed with a fix, got %v and %v", second.Err, second.Fixes)
+		}
+
+		// the report is reset by each call
+		calls = 1
+		if _, err := p.Execute(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(report.Attempts) != 1 {
+			t.Errorf("Expected 1 attempt, got %v", len(report.Attempts))
+		}
+	})
+
+	t.Run("it should report model errors", func(t *testing.T) {
+		m := mockModelClient{err: errors.New("connection refused")}
+
+		var report Report
+		p := NewPrompt[Result](m, "", PromptReport[Result](&report))
+		if _, err := p.Execute(context.Background()); err == nil {
+			t.Fatal("Expected an error")
+		}
+		if len(report.Attempts) != 1 || !errors.Is(report.Attempts[0].Err, m.err) {
+			t.Errorf("Expected 1 failed attempt, got %+v", report.Attempts)
+		}
+	})
 }
@@ -0,0 +1,249 @@
+package typechat
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const tsIndent = "    "
+
+type tsRenderer struct{}
+
+func (tsRenderer) language() string {
+	return "TypeScript"
+}
+
+// decls renders the declarations of s as TypeScript interfaces and type aliases, in dependency order.
+func (tsRenderer) decls(s *schema) string {
+	var sb strings.Builder
+	for _, d := range s.decls {
+		sb.WriteString(tsDecl(d))
+	}
+
+	return sb.String()
+}
+
+// api renders the API interface as a TypeScript interface, with the registered descriptions of its methods and
+// parameters as JSDoc comments. Context parameters and error results are left out.
+func (tsRenderer) api(a *apiSchema) string {
+	var methods strings.Builder
+	for _, m := range a.methods {
+		doc := []string{m.description}
+		for _, p := range m.params {
+			if p.description != "" {
+				doc = append(doc, fmt.Sprintf("@param %s %s", p.name, p.description))
+			}
+		}
+		methods.WriteString(jsDoc(strings.Join(doc, "\n"), tsIndent))
+
+		var args []string
+		for _, p := range m.params {
+			args = append(args, fmt.Sprintf("%s: %s", p.name, tsExpr(p.expr, tsIndent)))
+		}
+
+		var returns []string
+		for _, r := range m.results {
+			if r.expr == nil {
+				returns = append(returns, tsTypeName(r.typ))
+				continue
+			}
+			returns = append(returns, tsExpr(r.expr, tsIndent))
+		}
+
+		var result string
+		switch len(returns) {
+		case 0:
+			result = "void"
+		case 1:
+			result = returns[0]
+		default:
+			result = fmt.Sprintf("[%s]", strings.Join(returns, ", "))
+		}
+
+		methods.WriteString(fmt.Sprintf("%s%s(%s): %s;\n", tsIndent, m.name, strings.Join(args, ", "), result))
+	}
+
+	return fmt.Sprintf("interface %s {\n%s}\n", tsName(a.name), methods.String())
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// tsTypeName names a method parameter or result type without declaring it.
+func tsTypeName(t reflect.Type) string {
+	t, _ = indirect(t)
+	switch {
+	case t.Name() != "" && t.PkgPath() != "":
+		return tsName(t.Name())
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return tsArray(tsTypeName(t.Elem()))
+	case t.Kind() == reflect.Map:
+		return fmt.Sprintf("Record<%s, %s>", tsTypeName(t.Key()), tsTypeName(t.Elem()))
+	default:
+		return tsScalar(t.Kind())
+	}
+}
+
+func tsDecl(d *typeDecl) string {
+	var sb strings.Builder
+	sb.WriteString(jsDoc(d.description, ""))
+
+	name := tsName(d.name)
+	switch d.kind {
+	case declStruct:
+		sb.WriteString(fmt.Sprintf("interface %s %s\n", name, tsObject(d, "")))
+	case declScalar:
+		typ := tsScalar(d.scalar)
+		if d.enum != nil {
+			values := make([]string, len(d.enum))
+			for i, l := range d.enum {
+				values[i] = l.value
+				if l.label != "" {
+					values[i] = fmt.Sprintf("%s /* %s */", l.value, l.label)
+				}
+			}
+			typ = strings.Join(values, " | ")
+		}
+		sb.WriteString(fmt.Sprintf("type %s = %s;\n", name, typ))
+	case declAlias:
+		sb.WriteString(fmt.Sprintf("type %s = %s;\n", name, tsExpr(d.alias, "")))
+	}
+
+	return sb.String()
+}
+
+func tsObject(d *typeDecl, indent string) string {
+	var fields strings.Builder
+	for _, f := range d.fields {
+		fieldIndent := indent + tsIndent
+		fields.WriteString(jsDoc(f.description, fieldIndent))
+
+		name := tsProperty(f.name)
+		if f.optional {
+			name += "?"
+		}
+
+		// Optional properties already accept a missing value, so pointers are not also marked as nullable.
+		typ := *f.typ
+		typ.nullable = false
+		typName := tsExpr(&typ, fieldIndent)
+
+		var comments []string
+		if f.typ.format == "base64" {
+			comments = append(comments, "base64 encoded")
+		}
+		// oneof constraints on plain values are written as a union of literals, like enums.
+		withOneOf := true
+		if c := f.constraints; c != nil && c.oneOf != nil && !f.quoted && exprShape(&typ) != shapeOther {
+			typName = strings.Join(c.oneOfLiterals(exprShape(&typ)), " | ")
+			withOneOf = false
+		}
+		if f.quoted {
+			comments = append(comments, fmt.Sprintf("%s encoded as a JSON string", typName))
+			typName = "string"
+		}
+		if f.constraints != nil {
+			comments = append(comments, f.constraints.describe(f.typ, withOneOf)...)
+		}
+
+		var comment string
+		if len(comments) > 0 {
+			comment = fmt.Sprintf(" // %s", strings.Join(comments, ", "))
+		}
+
+		fields.WriteString(fmt.Sprintf("%s%s: %s;%s\n", fieldIndent, name, typName, comment))
+	}
+
+	return fmt.Sprintf("{\n%s%s}", fields.String(), indent)
+}
+
+func tsExpr(e *typeExpr, indent string) string {
+	var name string
+	switch e.kind {
+	case exprScalar:
+		name = tsScalar(e.scalar)
+	case exprAny:
+		name = "any"
+	case exprSlice:
+		name = tsArray(tsExpr(e.elem, indent))
+	case exprMap:
+		name = fmt.Sprintf("Record<%s, %s>", tsExpr(e.key, indent), tsExpr(e.elem, indent))
+	case exprNamed:
+		name = tsName(e.decl.name)
+		if name == "" {
+			name = tsObject(e.decl, indent)
+		}
+	}
+
+	if e.nullable {
+		name += " | null"
+	}
+
+	return name
+}
+
+func tsArray(elem string) string {
+	if strings.Contains(elem, " | ") {
+		elem = fmt.Sprintf("(%s)", elem)
+	}
+
+	return elem + "[]"
+}
+
+func tsScalar(k reflect.Kind) string {
+	switch k {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	default:
+		return "any"
+	}
+}
+
+var (
+	tsIdentifier        = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+	tsInvalidIdentifier = regexp.MustCompile(`[^A-Za-z0-9_$]+`)
+)
+
+// tsName turns a declared type name into a TypeScript identifier, such as Page_User for the generic Page[User].
+func tsName(name string) string {
+	return strings.Trim(tsInvalidIdentifier.ReplaceAllString(name, "_"), "_")
+}
+
+// tsProperty quotes property names that are not valid identifiers.
+func tsProperty(name string) string {
+	if tsIdentifier.MatchString(name) {
+		return name
+	}
+
+	return strconv.Quote(name)
+}
+
+// jsDoc formats a description as a JSDoc comment.
+func jsDoc(description, indent string) string {
+	description = strings.TrimSpace(description)
+	if description == "" {
+		return ""
+	}
+
+	lines := strings.Split(description, "\n")
+	if len(lines) == 1 {
+		return fmt.Sprintf("%s/** %s */\n", indent, lines[0])
+	}
+
+	var sb strings.Builder
+	sb.WriteString(indent + "/**\n")
+	for _, line := range lines {
+		sb.WriteString(strings.TrimRight(fmt.Sprintf("%s * %s", indent, strings.TrimSpace(line)), " ") + "\n")
+	}
+	sb.WriteString(indent + " */\n")
+
+	return sb.String()
+}
@@ -0,0 +1,101 @@
+package typechat
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testTicket struct {
+	Title    string         `json:"title" description:"a short summary"`
+	Priority testPriority   `json:"priority,omitempty"`
+	Mood     testSentiment  `json:"mood"`
+	Assignee *testUserID    `json:"assignee"`
+	Labels   map[string]int `json:"labels"`
+	Related  []*testTicket  `json:"related-tickets"`
+	Count    int            `json:"count,string"`
+	Location struct {
+		Lat float64 `json:"lat"`
+		Lng float64 `json:"lng"`
+	} `json:"location"`
+}
+
+func TestTypeScriptRenderer(t *testing.T) {
+	RegisterEnum[testPriority]("low", "medium", "high")
+
+	t.Run("it renders declarations as TypeScript", func(t *testing.T) {
+		s, _, err := structSchema(reflect.TypeOf(testTicket{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `
+type testPriority = "low" | "medium" | "high";
+type testSentiment = 0 /* positive */ | 1 /* negative */ | 2 /* neutral */;
+/** testUserID is the unique identifier of a user account. */
+type testUserID = string;
+interface testTicket {
+    /** a short summary */
+    title: string;
+    priority?: testPriority;
+    mood: testSentiment;
+    assignee?: testUserID;
+    labels: Record<string, number>;
+    "related-tickets": (testTicket | null)[];
+    count: string; // number encoded as a JSON string
+    location: {
+        lat: number;
+        lng: number;
+    };
+}`
+		assertNameDefOuptut(t, tsRenderer{}.decls(s), expected)
+	})
+
+	t.Run("it renders the API interface as TypeScript", func(t *testing.T) {
+		type API interface {
+			Search(query string, limit int) ([]testTicket, error)
+			Close(id testUserID) error
+		}
+
+		a, err := newAPISchema(reflect.TypeOf((*API)(nil)).Elem(), newSchema())
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		def := tsRenderer{}.api(a)
+
+		expected := `
+interface API {
+    Close(arg0: testUserID): void;
+    Search(arg0: string, arg1: number): testTicket[];
+}`
+		assertNameDefOuptut(t, def, expected)
+	})
+
+	t.Run("it sanitizes generic type names", func(t *testing.T) {
+		if name := tsName("testPage[testNode]"); name != "testPage_testNode" {
+			t.Errorf("expected testPage_testNode, got %s", name)
+		}
+	})
+
+	t.Run("it uses the dialect selected for the prompt", func(t *testing.T) {
+		type Result struct {
+			Sentiment string `json:"sentiment"`
+		}
+
+		var system string
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			system = prompt[0].Content
+			return `{"sentiment": "positive"}`, nil
+		})
+
+		p := NewPrompt[Result](m, "", PromptSchemaDialect[Result](DialectTypeScript))
+		if _, err := p.Execute(context.Background()); err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		if !strings.Contains(system, "TypeScript definitions") || !strings.Contains(system, "interface Result {") {
+			t.Errorf("expected a TypeScript schema, got:\n%s", system)
+		}
+	})
+}
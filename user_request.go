@@ -8,7 +8,7 @@ import (
 
 const (
 	userRequestSchemaInstructions = `You are a service that translates user requests into JSON objects of type %s 
-according to the following Go definitions:`
+according to the following %s definitions:`
 
 	userRequestPromptInstructions = `The following is the user request translated into a JSON object with 2 spaces of 
 indentation and no properties with the value undefined:`
@@ -16,11 +16,15 @@ indentation and no properties with the value undefined:`
 
 type userRequest[T any] struct {
 	input    string
+	renderer renderer
 	messages []Message
+
+	schemaDecls *schema
+	schemaRoot  *typeDecl
 }
 
-func newUserRequest[T any](i string) *userRequest[T] {
-	return &userRequest[T]{input: i}
+func newUserRequest[T any](i string, r renderer) *userRequest[T] {
+	return &userRequest[T]{input: i, renderer: r}
 }
 
 func (b *userRequest[T]) prompt() ([]Message, error) {
@@ -28,23 +32,43 @@ func (b *userRequest[T]) prompt() ([]Message, error) {
 		return b.messages, nil
 	}
 
-	var schema T
-	name, def, err := structDef(reflect.TypeOf(schema))
+	s, decl, err := b.responseSchema()
 	if err != nil {
 		return nil, err
 	}
 
-	b.messages = append(b.messages, newSystemMessage(b.schema(name, def)))
+	b.messages = append(b.messages, newSystemMessage(b.schema(decl.name, b.renderer.decls(s))))
+	// The instructions follow the request in the same message, since some providers only accept a leading system
+	// message.
 	b.messages = append(b.messages, newUserMessage(b.userMessage()))
-	b.messages = append(b.messages, newSystemMessage(b.instructions()))
 
 	return b.messages, nil
 }
 
+func (b *userRequest[T]) responseSchema() (*schema, *typeDecl, error) {
+	if b.schemaRoot != nil {
+		return b.schemaDecls, b.schemaRoot, nil
+	}
+
+	var schema T
+	s, decl, err := structSchema(reflect.TypeOf(schema))
+	if err != nil {
+		return nil, nil, err
+	}
+	b.schemaDecls, b.schemaRoot = s, decl
+
+	return s, decl, nil
+}
+
+func (b *userRequest[T]) validate(output any) error {
+	return nil
+}
+
 func (b *userRequest[T]) userMessage() string {
 	var sb strings.Builder
 	sb.WriteString(newline("The following is a user request:"))
 	sb.WriteString(newline(b.input))
+	sb.WriteString(b.instructions())
 
 	return sb.String()
 }
@@ -55,7 +79,7 @@ func (b *userRequest[T]) instructions() string {
 
 func (b *userRequest[T]) schema(name, def string) string {
 	var sb strings.Builder
-	sb.WriteString(newline(fmt.Sprintf(userRequestSchemaInstructions, name)))
+	sb.WriteString(newline(fmt.Sprintf(userRequestSchemaInstructions, name, b.renderer.language())))
 	sb.WriteString(newline(def))
 
 	return sb.String()
@@ -0,0 +1,480 @@
+package typechat
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// violation is a single place where a response does not match its schema.
+type violation struct {
+	path    string
+	message string
+}
+
+func (v violation) String() string {
+	return fmt.Sprintf("%s: %s", v.path, v.message)
+}
+
+// violations is returned when a response is valid JSON that does not match its schema. Each violation is written on
+// its own line so the model can fix all of them at once.
+type violations []violation
+
+func (v violations) Error() string {
+	lines := make([]string, len(v))
+	for i, vi := range v {
+		lines[i] = vi.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// validateJSON checks that data is a JSON object matching the struct declaration root. Unlike json.Unmarshal it
+// reports unknown properties, missing required properties and enum values outside of their set, and it keeps going
+// after the first problem so every violation is reported.
+func validateJSON(data []byte, root *typeDecl) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid data after the top-level JSON value")
+	}
+
+	var c validator
+	c.decl(root, v, "$")
+	if len(c.violations) > 0 {
+		return c.violations
+	}
+
+	return nil
+}
+
+type validator struct {
+	violations violations
+	// ref, if not nil, checks the references to earlier steps nested in the arguments of a program, where a value
+	// described by want is expected.
+	ref func(r Ref, want *typeExpr, path string)
+}
+
+func (c *validator) add(path, format string, args ...any) {
+	c.violations = append(c.violations, violation{path: path, message: fmt.Sprintf(format, args...)})
+}
+
+func (c *validator) decl(d *typeDecl, v any, path string) {
+	switch d.kind {
+	case declStruct:
+		c.object(d, v, path)
+	case declScalar:
+		if d.unbounded {
+			if n, ok := v.(json.Number); !ok || !bigInteger(n) {
+				c.add(path, "expected an integer, got %s", jsonText(v))
+			}
+			return
+		}
+		if !c.scalar(d.scalar, v, path) {
+			return
+		}
+		if d.format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, v.(string)); err != nil {
+				c.add(path, "expected an RFC 3339 timestamp, got %s", jsonText(v))
+				return
+			}
+		}
+		if d.enum != nil {
+			c.enum(d.enum, v, path)
+		}
+	case declAlias:
+		c.expr(d.alias, v, path)
+	}
+}
+
+func (c *validator) object(d *typeDecl, v any, path string) {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		c.add(path, "expected an object, got %s", jsonKind(v))
+		return
+	}
+
+	matched := make(map[string]bool, len(obj))
+	for _, f := range d.fields {
+		key, ok := lookupProperty(obj, f.name)
+		p := propertyPath(path, f.name)
+		if !ok {
+			if !f.optional {
+				c.add(p, "missing required property")
+			}
+			continue
+		}
+		matched[key] = true
+
+		value := obj[key]
+		n := len(c.violations)
+		if f.quoted {
+			value = c.quoted(f.typ, value, p)
+		} else {
+			c.expr(f.typ, value, p)
+		}
+		if f.constraints != nil && (value != nil || nullIsEmpty(f.typ)) && len(c.violations) == n {
+			c.constraints(f.constraints, f.typ, value, p)
+		}
+	}
+
+	var unknown []string
+	for key := range obj {
+		if !matched[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		c.add(propertyPath(path, key), "unknown property")
+	}
+}
+
+// lookupProperty finds the key of a property the way json.Unmarshal does, preferring an exact match but falling back
+// to a case-insensitive one.
+func lookupProperty(obj map[string]any, name string) (string, bool) {
+	if _, ok := obj[name]; ok {
+		return name, true
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+func (c *validator) expr(e *typeExpr, v any, path string) {
+	if r, ok := v.(Ref); ok && c.ref != nil {
+		c.ref(r, e, path)
+		return
+	}
+	if v == nil {
+		if !acceptsNull(e) {
+			c.add(path, "expected %s, got null", exprKindName(e))
+		}
+		return
+	}
+
+	switch e.kind {
+	case exprScalar:
+		if !c.scalar(e.scalar, v, path) {
+			return
+		}
+		if e.format == "base64" {
+			if _, err := base64.StdEncoding.DecodeString(v.(string)); err != nil {
+				c.add(path, "expected a base64 encoded string")
+			}
+		}
+	case exprSlice:
+		items, ok := v.([]any)
+		if !ok {
+			c.add(path, "expected an array, got %s", jsonKind(v))
+			return
+		}
+		for i, item := range items {
+			c.expr(e.elem, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case exprMap:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			c.add(path, "expected an object, got %s", jsonKind(v))
+			return
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			p := fmt.Sprintf("%s[%q]", path, key)
+			c.mapKey(e.key, key, p)
+			c.expr(e.elem, obj[key], p)
+		}
+	case exprNamed:
+		c.decl(e.decl, v, path)
+	}
+}
+
+// mapKey checks a map key, which is always a string in JSON, against the key type of the map.
+func (c *validator) mapKey(e *typeExpr, key, path string) {
+	k := e.scalar
+	if e.kind == exprNamed {
+		if e.decl.kind != declScalar {
+			return
+		}
+		k = e.decl.scalar
+	}
+
+	switch {
+	case k == reflect.String && e.kind == exprNamed:
+		c.decl(e.decl, key, path)
+	case intKind(k):
+		if _, err := strconv.ParseInt(key, 10, kindBits(k)); err != nil {
+			c.add(path, "expected the key to be an integer")
+		}
+	case uintKind(k):
+		if _, err := strconv.ParseUint(key, 10, kindBits(k)); err != nil {
+			c.add(path, "expected the key to be a non-negative integer")
+		}
+	}
+}
+
+// quoted checks a value that the string option of its json tag encodes inside a JSON string and returns the value
+// it encodes.
+func (c *validator) quoted(e *typeExpr, v any, path string) any {
+	if v == nil && e.nullable {
+		return nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		c.add(path, "expected %s encoded as a JSON string, got %s", exprKindName(e), jsonKind(v))
+		return nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var inner any
+	if err := dec.Decode(&inner); err != nil {
+		c.add(path, "expected %s encoded as a JSON string, got %s", exprKindName(e), jsonText(v))
+		return nil
+	}
+	c.expr(e, inner, path)
+
+	return inner
+}
+
+// scalar checks that v is a JSON value of the scalar kind k and reports whether it is.
+func (c *validator) scalar(k reflect.Kind, v any, path string) bool {
+	var ok bool
+	switch {
+	case k == reflect.Bool:
+		_, ok = v.(bool)
+	case k == reflect.String:
+		_, ok = v.(string)
+	case k == reflect.Interface:
+		ok = true
+	case intKind(k), uintKind(k), k == reflect.Float32, k == reflect.Float64:
+		n, isNumber := v.(json.Number)
+		if !isNumber {
+			break
+		}
+		return c.number(k, n, path)
+	}
+
+	if !ok {
+		c.add(path, "expected %s, got %s", scalarKindName(k), jsonKind(v))
+	}
+
+	return ok
+}
+
+func (c *validator) number(k reflect.Kind, n json.Number, path string) bool {
+	var err error
+	switch {
+	case intKind(k):
+		_, err = strconv.ParseInt(n.String(), 10, kindBits(k))
+	case uintKind(k):
+		_, err = strconv.ParseUint(n.String(), 10, kindBits(k))
+	default:
+		_, err = strconv.ParseFloat(n.String(), kindBits(k))
+	}
+
+	if err == nil {
+		return true
+	}
+
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) && errors.Is(numErr.Err, strconv.ErrRange) {
+		c.add(path, "%s is out of range for %s", n, k)
+	} else {
+		c.add(path, "expected %s, got %s", scalarKindName(k), n)
+	}
+
+	return false
+}
+
+func (c *validator) enum(literals []enumLiteral, v any, path string) {
+	for _, l := range literals {
+		if jsonEqual(l.value, v) {
+			return
+		}
+	}
+
+	allowed := make([]string, len(literals))
+	for i, l := range literals {
+		allowed[i] = l.String()
+	}
+	c.add(path, "%s is not one of %s", jsonText(v), strings.Join(allowed, ", "))
+}
+
+// jsonEqual reports whether the JSON literal equals the decoded value v. Numbers are compared by value, so 1 and
+// 1.0 are equal.
+func jsonEqual(literal string, v any) bool {
+	dec := json.NewDecoder(strings.NewReader(literal))
+	dec.UseNumber()
+	var l any
+	if err := dec.Decode(&l); err != nil {
+		return false
+	}
+
+	ln, lok := l.(json.Number)
+	vn, vok := v.(json.Number)
+	if lok && vok {
+		x, xok := new(big.Rat).SetString(ln.String())
+		y, yok := new(big.Rat).SetString(vn.String())
+		return xok && yok && x.Cmp(y) == 0
+	}
+
+	return l == v
+}
+
+// bigInteger reports whether n is an integer, regardless of its size.
+func bigInteger(n json.Number) bool {
+	_, ok := new(big.Int).SetString(n.String(), 10)
+	return ok
+}
+
+func acceptsNull(e *typeExpr) bool {
+	switch {
+	case e.nullable, e.kind == exprAny, e.kind == exprSlice, e.kind == exprMap:
+		return true
+	case e.kind == exprNamed && e.decl.kind == declAlias:
+		return acceptsNull(e.decl.alias)
+	case e.kind == exprNamed && e.decl.kind == declScalar:
+		return e.decl.scalar == reflect.Interface
+	}
+
+	return false
+}
+
+func propertyPath(path, name string) string {
+	if tsIdentifier.MatchString(name) {
+		return fmt.Sprintf("%s.%s", path, name)
+	}
+
+	return fmt.Sprintf("%s[%q]", path, name)
+}
+
+func exprKindName(e *typeExpr) string {
+	switch e.kind {
+	case exprScalar:
+		return scalarKindName(e.scalar)
+	case exprSlice:
+		return "an array"
+	case exprMap:
+		return "an object"
+	case exprNamed:
+		switch e.decl.kind {
+		case declStruct:
+			return "an object"
+		case declScalar:
+			return scalarKindName(e.decl.scalar)
+		case declAlias:
+			return exprKindName(e.decl.alias)
+		}
+	}
+
+	return "a value"
+}
+
+func scalarKindName(k reflect.Kind) string {
+	switch {
+	case k == reflect.Bool:
+		return "a boolean"
+	case k == reflect.String:
+		return "a string"
+	case intKind(k):
+		return "an integer"
+	case uintKind(k):
+		return "a non-negative integer"
+	case k == reflect.Float32, k == reflect.Float64:
+		return "a number"
+	}
+
+	return "a value"
+}
+
+// jsonKind names the kind of a decoded JSON value for error messages.
+func jsonKind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case json.Number:
+		return "a number"
+	case string:
+		return "a string"
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	}
+
+	return "an unknown value"
+}
+
+// jsonText formats a decoded JSON scalar as it appeared in the response.
+func jsonText(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
+
+func intKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+
+	return false
+}
+
+func uintKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+
+	return false
+}
+
+// kindBits returns the size in bits of a numeric kind, for use with strconv.
+func kindBits(k reflect.Kind) int {
+	switch k {
+	case reflect.Int8, reflect.Uint8:
+		return 8
+	case reflect.Int16, reflect.Uint16:
+		return 16
+	case reflect.Int32, reflect.Uint32, reflect.Float32:
+		return 32
+	}
+
+	return 64
+}
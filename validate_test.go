@@ -0,0 +1,144 @@
+package typechat
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func assertViolations(t *testing.T, err error, expected ...string) {
+	t.Helper()
+
+	if len(expected) == 0 {
+		if err != nil {
+			t.Errorf("expected err to be nil, got:\n%s", err)
+		}
+		return
+	}
+
+	if err == nil {
+		t.Fatalf("expected err to be:\n%s\ngot nil", strings.Join(expected, "\n"))
+	}
+	if err.Error() != strings.Join(expected, "\n") {
+		t.Errorf("expected err to be:\n%s\ngot:\n%s", strings.Join(expected, "\n"), err)
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	type Address struct {
+		City string `json:"city"`
+		Zip  *int   `json:"zip"`
+	}
+
+	type Person struct {
+		Name     string                     `json:"name"`
+		Age      uint8                      `json:"age"`
+		Nickname string                     `json:"nickname,omitempty"`
+		Address  Address                    `json:"address"`
+		Previous []Address                  `json:"previous"`
+		Scores   map[int]float64            `json:"scores"`
+		Extra    map[string]json.RawMessage `json:"extra"`
+		Born     *time.Time                 `json:"born"`
+		Count    int                        `json:"count,string"`
+		Tags     []string                   `json:"tags"`
+		Labels   map[string]string          `json:"labels"`
+	}
+
+	_, root, err := structSchema(reflect.TypeOf(Person{}))
+	if err != nil {
+		t.Fatalf("expected err to be nil, got %s", err)
+	}
+
+	t.Run("it accepts valid responses", func(t *testing.T) {
+		err := validateJSON([]byte(`{
+			"name": "Jane",
+			"age": 42,
+			"address": {"city": "Paris", "zip": null},
+			"previous": [{"city": "Lyon", "zip": 69000}],
+			"scores": {"1": 1.5, "-2": 3},
+			"extra": {"anything": [1, "two", null]},
+			"born": "1981-04-12T08:00:00Z",
+			"count": "12",
+			"tags": null,
+			"labels": {}
+		}`), root)
+		assertViolations(t, err)
+	})
+
+	t.Run("it matches property names case-insensitively like encoding/json", func(t *testing.T) {
+		err := validateJSON([]byte(`{
+			"Name": "Jane", "AGE": 42, "address": {"City": "Paris"}, "previous": [], "scores": {},
+			"extra": {}, "count": "1", "tags": [], "labels": {}
+		}`), root)
+		assertViolations(t, err)
+	})
+
+	t.Run("it reports every violation with its path", func(t *testing.T) {
+		err := validateJSON([]byte(`{
+			"name": null,
+			"age": 300,
+			"address": {"city": 12, "country": "FR"},
+			"previous": [{"city": "Lyon"}, "Marseille"],
+			"scores": {"one": 1},
+			"extra": [],
+			"born": "yesterday",
+			"count": 12,
+			"tags": [1.5],
+			"favorite color": "blue"
+		}`), root)
+		assertViolations(t, err,
+			`$.name: expected a string, got null`,
+			`$.age: 300 is out of range for uint8`,
+			`$.address.city: expected a string, got a number`,
+			`$.address.country: unknown property`,
+			`$.previous[1]: expected an object, got a string`,
+			`$.scores["one"]: expected the key to be an integer`,
+			`$.extra: expected an object, got an array`,
+			`$.born: expected an RFC 3339 timestamp, got "yesterday"`,
+			`$.count: expected an integer encoded as a JSON string, got a number`,
+			`$.tags[0]: expected a string, got a number`,
+			`$.labels: missing required property`,
+			`$["favorite color"]: unknown property`,
+		)
+	})
+
+	t.Run("it rejects invalid JSON", func(t *testing.T) {
+		if err := validateJSON([]byte(`{"name": "Jane"`), root); err == nil {
+			t.Error("expected err to be non-nil")
+		}
+		if err := validateJSON([]byte(`{} {}`), root); err == nil {
+			t.Error("expected err to be non-nil")
+		}
+		assertViolations(t, validateJSON([]byte(`[]`), root), `$: expected an object, got an array`)
+	})
+
+	t.Run("it sends the violations to the model for repair", func(t *testing.T) {
+		type Result struct {
+			Sentiment string `json:"sentiment"`
+		}
+
+		var prompts [][]Message
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			prompts = append(prompts, prompt)
+			return `{"feeling": "positive"}`, nil
+		})
+
+		p := NewPrompt[Result](m, "", PromptRetries[Result](1))
+		if _, err := p.Execute(context.Background()); err == nil {
+			t.Fatal("expected err to be non-nil")
+		}
+
+		if len(prompts) != 2 {
+			t.Fatalf("expected 2 prompts, got %d", len(prompts))
+		}
+		repair := prompts[1][len(prompts[1])-1].Content
+		for _, v := range []string{"$.sentiment: missing required property", "$.feeling: unknown property"} {
+			if !strings.Contains(repair, v) {
+				t.Errorf("expected repair prompt to contain %q, got:\n%s", v, repair)
+			}
+		}
+	})
+}
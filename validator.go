@@ -0,0 +1,56 @@
+package typechat
+
+import (
+	"context"
+	"errors"
+)
+
+// Validator checks a decoded result against rules that the schema cannot express, such as an end date being after a
+// start date. A returned error is sent to the model so it can correct its response, the same way JSON errors are.
+type Validator[T any] interface {
+	Validate(ctx context.Context, result T) error
+}
+
+// ValidatorFunc adapts a function to the Validator interface.
+type ValidatorFunc[T any] func(ctx context.Context, result T) error
+
+// Validate calls f(ctx, result).
+func (f ValidatorFunc[T]) Validate(ctx context.Context, result T) error {
+	return f(ctx, result)
+}
+
+// validatable is implemented by result types that validate themselves.
+type validatable interface {
+	Validate() error
+}
+
+// PromptValidators adds validators that are run, in order, on every result decoded by Execute. They run after the
+// Validate method of T, if it has one.
+func PromptValidators[T any](validators ...Validator[T]) opt[T] {
+	return func(t *Prompt[T]) {
+		t.validators = append(t.validators, validators...)
+	}
+}
+
+// validate runs the Validate method of the result and the validators of the prompt, returning all of their errors.
+func (p *Prompt[T]) validate(ctx context.Context, result *T) error {
+	var errs []error
+
+	var v any = result
+	if _, ok := v.(validatable); !ok {
+		v = *result
+	}
+	if r, ok := v.(validatable); ok {
+		if err := r.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, validator := range p.validators {
+		if err := validator.Validate(ctx, *result); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
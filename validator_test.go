@@ -0,0 +1,99 @@
+package typechat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testBooking struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+func (b testBooking) Validate() error {
+	if b.End <= b.Start {
+		return errors.New("end must be after start")
+	}
+
+	return nil
+}
+
+func TestValidators(t *testing.T) {
+	t.Run("it accepts results passing every validator", func(t *testing.T) {
+		m := mockModelClient{response: `{"start": 1, "end": 2}`}
+		var called bool
+		validator := ValidatorFunc[testBooking](func(ctx context.Context, b testBooking) error {
+			called = true
+			return nil
+		})
+
+		p := NewPrompt[testBooking](m, "", PromptValidators[testBooking](validator))
+		result, err := p.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if !called {
+			t.Error("expected validator to be called")
+		}
+		if result.End != 2 {
+			t.Errorf("expected end to be 2, got %d", result.End)
+		}
+	})
+
+	t.Run("it sends validation errors to the model for repair", func(t *testing.T) {
+		var prompts [][]Message
+		m := funcModelClient(func(ctx context.Context, prompt []Message) (string, error) {
+			prompts = append(prompts, prompt)
+			return `{"start": 3, "end": 2}`, nil
+		})
+		validator := ValidatorFunc[testBooking](func(ctx context.Context, b testBooking) error {
+			if b.End-b.Start > 10 || b.Start < 5 {
+				return errors.New("bookings must start at 5 or later")
+			}
+			return nil
+		})
+
+		p := NewPrompt[testBooking](m, "", PromptRetries[testBooking](1), PromptValidators[testBooking](validator))
+		if _, err := p.Execute(context.Background()); err == nil {
+			t.Fatal("expected err to be non-nil")
+		}
+
+		if len(prompts) != 2 {
+			t.Fatalf("expected 2 prompts, got %d", len(prompts))
+		}
+		repair := prompts[1][len(prompts[1])-1].Content
+		for _, msg := range []string{"end must be after start", "bookings must start at 5 or later"} {
+			if !strings.Contains(repair, msg) {
+				t.Errorf("expected repair prompt to contain %q, got:\n%s", msg, repair)
+			}
+		}
+	})
+
+	t.Run("it calls Validate methods with pointer receivers", func(t *testing.T) {
+		m := mockModelClient{response: `{"value": -1}`}
+		p := NewPrompt[testQuantity](m, "")
+		if _, err := p.Execute(context.Background()); err == nil {
+			t.Fatal("expected err to be non-nil")
+		}
+
+		m = mockModelClient{response: `{"value": 1}`}
+		p = NewPrompt[testQuantity](m, "")
+		if _, err := p.Execute(context.Background()); err != nil {
+			t.Errorf("expected err to be nil, got %s", err)
+		}
+	})
+}
+
+type testQuantity struct {
+	Value int `json:"value"`
+}
+
+func (p *testQuantity) Validate() error {
+	if p.Value <= 0 {
+		return errors.New("value must be positive")
+	}
+
+	return nil
+}
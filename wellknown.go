@@ -0,0 +1,102 @@
+package typechat
+
+import (
+	"encoding"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/netip"
+	"reflect"
+	"time"
+)
+
+// wellKnownType describes how a standard library type is encoded by encoding/json when reflecting over it would
+// show its internal representation instead.
+type wellKnownType struct {
+	scalar      reflect.Kind // JSON shape of the encoded value, reflect.Interface for any JSON value
+	format      string       // JSON Schema format of string values
+	unbounded   bool         // integers of any size are accepted
+	description string
+}
+
+var wellKnownTypes = map[reflect.Type]wellKnownType{
+	reflect.TypeOf(time.Time{}): {
+		scalar:      reflect.String,
+		format:      "date-time",
+		description: `Time is a timestamp in RFC 3339 format, such as "2006-01-02T15:04:05Z".`,
+	},
+	reflect.TypeOf(time.Duration(0)): {
+		scalar:      reflect.Int64,
+		description: "Duration is a length of time in nanoseconds.",
+	},
+	reflect.TypeOf(json.RawMessage{}): {
+		scalar: reflect.Interface,
+	},
+	reflect.TypeOf(json.Number("")): {
+		scalar:      reflect.Float64,
+		description: "Number is a JSON number of any size or precision.",
+	},
+	reflect.TypeOf(big.Int{}): {
+		scalar:      reflect.Int,
+		unbounded:   true,
+		description: "Int is an integer of any size.",
+	},
+	reflect.TypeOf(big.Float{}): {
+		scalar:      reflect.String,
+		description: `Float is a decimal number of any precision written as a string, such as "3.14159".`,
+	},
+	reflect.TypeOf(big.Rat{}): {
+		scalar:      reflect.String,
+		description: `Rat is a fraction written as a string, such as "3/4".`,
+	},
+	reflect.TypeOf(net.IP{}): {
+		scalar:      reflect.String,
+		description: `IP is an IPv4 or IPv6 address, such as "192.0.2.1" or "2001:db8::1".`,
+	},
+	reflect.TypeOf(netip.Addr{}): {
+		scalar:      reflect.String,
+		description: `Addr is an IPv4 or IPv6 address, such as "192.0.2.1" or "2001:db8::1".`,
+	},
+	reflect.TypeOf(netip.AddrPort{}): {
+		scalar:      reflect.String,
+		description: `AddrPort is an IP address and port, such as "192.0.2.1:80" or "[2001:db8::1]:80".`,
+	},
+	reflect.TypeOf(netip.Prefix{}): {
+		scalar:      reflect.String,
+		description: `Prefix is an IP network in CIDR notation, such as "192.0.2.0/24".`,
+	},
+}
+
+var (
+	jsonMarshalerInterface = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerInterface = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// lookupWellKnown returns the encoding of t if it is a well-known type. Types that implement encoding.TextMarshaler
+// but not json.Marshaler, such as most UUID types, are encoded by encoding/json as strings and are well-known too.
+func lookupWellKnown(t reflect.Type) (wellKnownType, bool) {
+	if w, ok := wellKnownTypes[t]; ok {
+		return w, true
+	}
+
+	if t.Name() != "" && implements(t, textMarshalerInterface) && !implements(t, jsonMarshalerInterface) {
+		return wellKnownType{scalar: reflect.String}, true
+	}
+
+	return wellKnownType{}, false
+}
+
+// byteSlice reports whether t is a byte slice, which encoding/json writes as a base64 encoded string.
+func byteSlice(t reflect.Type) bool {
+	if t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Uint8 {
+		return false
+	}
+
+	elem := t.Elem()
+	return !implements(elem, jsonMarshalerInterface) && !implements(elem, textMarshalerInterface)
+}
+
+// implements reports whether t or a pointer to t implements the interface i.
+func implements(t, i reflect.Type) bool {
+	return t.Implements(i) || reflect.PointerTo(t).Implements(i)
+}
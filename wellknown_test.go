@@ -0,0 +1,91 @@
+package typechat
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testUUID [16]byte
+
+func (u testUUID) MarshalText() ([]byte, error) {
+	return []byte("00000000-0000-0000-0000-000000000000"), nil
+}
+
+func (u *testUUID) UnmarshalText(b []byte) error {
+	return nil
+}
+
+func TestWellKnownTypes(t *testing.T) {
+	type Event struct {
+		ID        testUUID
+		CreatedAt time.Time
+		UpdatedAt *time.Time
+		Timeout   time.Duration
+		Payload   json.RawMessage
+		Amount    *big.Int
+		Host      net.IP
+		Data      []byte
+	}
+
+	t.Run("it renders well-known types as documented scalars", func(t *testing.T) {
+		_, def, err := structDef(reflect.TypeOf(Event{}))
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+
+		expected := `
+type testUUID string
+// Time is a timestamp in RFC 3339 format, such as "2006-01-02T15:04:05Z".
+type Time string
+// Duration is a length of time in nanoseconds.
+type Duration int64
+// Int is an integer of any size.
+type Int int
+// IP is an IPv4 or IPv6 address, such as "192.0.2.1" or "2001:db8::1".
+type IP string
+type Event struct {
+	ID testUUID
+	CreatedAt Time
+	UpdatedAt *Time // optional
+	Timeout Duration
+	Payload interface{}
+	Amount *Int // optional
+	Host IP
+	Data string // base64 encoded
+}`
+		assertNameDefOuptut(t, def, expected)
+	})
+
+	t.Run("it decodes responses using well-known types", func(t *testing.T) {
+		m := mockModelClient{
+			response: `{
+				"ID": "00000000-0000-0000-0000-000000000000",
+				"CreatedAt": "2023-07-20T10:00:00Z",
+				"Timeout": 5000000000,
+				"Payload": {"any": ["json"]},
+				"Amount": 123456789012345678901234567890,
+				"Host": "192.0.2.1",
+				"Data": "aGk="
+			}`,
+		}
+		p := NewPrompt[Event](m, "")
+		result, err := p.Execute(context.Background())
+		if err != nil {
+			t.Fatalf("expected err to be nil, got %s", err)
+		}
+		if result.Timeout != 5*time.Second {
+			t.Errorf("expected timeout to be 5s, got %s", result.Timeout)
+		}
+		if result.CreatedAt.Year() != 2023 {
+			t.Errorf("expected year to be 2023, got %d", result.CreatedAt.Year())
+		}
+		if string(result.Data) != "hi" {
+			t.Errorf("expected data to be hi, got %s", result.Data)
+		}
+	})
+}